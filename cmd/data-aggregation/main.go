@@ -1,12 +1,34 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"flag"
 	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/BahadirAhmedov/data-aggregation/internal/app"
 	"github.com/BahadirAhmedov/data-aggregation/internal/config"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	grpcserver "github.com/BahadirAhmedov/data-aggregation/internal/grpc-server"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/accesslog"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/auth"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/idempotency"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/metrics"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/authn"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/validation"
 	"github.com/gin-gonic/gin"
-	"os"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	swaggerFiles "github.com/swaggo/files" 
 	_ "github.com/BahadirAhmedov/data-aggregation/cmd/data-aggregation/docs"
@@ -27,57 +49,196 @@ import (
 // @host      localhost:8080
 // @BasePath  /
 
-// @securityDefinitions.basic  BasicAuth
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 "Bearer " followed by an HMAC-signed JWT
 
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 
-const (
-	envLocal = "local"
-)
-
 func main() {
-	cfg := config.MustLoad()
-	fmt.Println(cfg.Storage)
+	configPath := flag.String("config", "", "path to the config file, or CONFIG_PATH")
+	flag.Parse()
+
+	cfg := config.MustLoad(*configPath)
+
+	logger := setupLogger(cfg.Env, cfg.LogLevel)
+
+	logger.Info("starting data-aggregation",
+		slog.String("env", cfg.Env),
+		slog.String("storage_type", cfg.StorageType),
+	)
+
+	if cfg.Env == config.EnvProd {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	if err := validation.Register(); err != nil {
+		logger.Error("failed to register validators", sl.Err(err))
+		os.Exit(1)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	application := app.New(logger, cfg.StorageType, cfg.Storage, cfg.ExchangeRates, cfg.HTTPServer.ReadinessTimeout, registry)
+	handlers := application.Handlers
 
-	logger := setupLogger(envLocal)
 
-	handlers := app.New(logger, cfg.Storage.Host, cfg.Storage.Port, cfg.Storage.User,
-		cfg.Storage.Password, cfg.Storage.DbName)
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
 
+	// The request ID comes first so every later response carries it.
+	router.Use(requestid.Middleware(), metrics.Middleware(registry), accesslog.New(logger), httputil.Recovery(logger))
+	router.NoRoute(httputil.RouteNotFound())
+	router.NoMethod(httputil.MethodNotAllowed())
 
-	router := gin.Default()
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Probes stay out of authentication, orchestrators have no credentials.
+	router.GET("/healthz", application.Health.Liveness())
+	router.GET("/readyz", application.Health.Readiness(logger))
+	// Scraped by Prometheus, so it is left out of authentication like the docs.
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+
+	authenticator := authn.New(cfg.Auth)
+
+	// Every route below needs an API key or a bearer token.
+	router.Use(auth.Middleware(logger, authenticator))
+	adminOnly := auth.RequireRole(models.RoleAdmin)
+	// Retried creates must not create twice, so they may carry an
+	// Idempotency-Key.
+	idempotent := idempotency.New(logger, application.IdempotencyKeys, cfg.Idempotency)
 	
 	// Create
-	router.POST("/subscriptions", handlers.CreateSubscription(logger))
+	router.POST("/subscriptions", idempotent, handlers.CreateSubscription(logger))
 	// Read
  	router.GET("/subscriptions/:id", handlers.ReadSubscription(logger))
 	// Update
 	router.PUT("/subscriptions/:id", handlers.UpdateSubscription(logger))
+	router.PATCH("/subscriptions/:id", handlers.PatchSubscription(logger))
 	// Delete
 	router.DELETE("/subscriptions/:id", handlers.DeleteSubscription(logger))
+	// Restore
+	router.POST("/subscriptions/:id/restore", handlers.RestoreSubscription(logger))
+	// History
+	router.GET("/subscriptions/:id/history", handlers.SubscriptionHistory(logger))
 	// List
 	router.GET("/subscriptions", handlers.ListSubscription(logger))
 
 
 	// Sum
 	router.POST("/subscriptions/sum", handlers.SumSubscriptions(logger))
+	// Report
+	router.POST("/subscriptions/report", handlers.ReportSubscriptions(logger))
+
+	// Batch
+	router.POST("/subscriptions/batch", adminOnly, idempotent, handlers.BatchSubscriptions(logger))
+
+	// Import/Export
+	router.GET("/subscriptions/export", adminOnly, handlers.ExportSubscriptions(logger))
+	// A large upload takes longer than the server's read timeout allows.
+	router.POST("/subscriptions/import", adminOnly, httputil.NoReadDeadline(logger), idempotent, handlers.ImportSubscriptions(logger))
+
+	srv := &http.Server{
+		Addr:         cfg.HTTPServer.Address,
+		Handler:      router,
+		ReadTimeout:  cfg.HTTPServer.ReadTimeout,
+		WriteTimeout: cfg.HTTPServer.WriteTimeout,
+		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
+	}
 
-	
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	go func() {
+		logger.Info("starting server", slog.String("address", srv.Addr))
+
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("failed to start server", sl.Err(err))
+			os.Exit(1)
+		}
+	}()
 
-	router.Run()
+	// The gRPC API takes the same credentials and calls the same service.
+	grpcSrv, grpcHealth := grpcserver.New(logger, authenticator, application.Subscriptions)
+
+	listener, err := net.Listen("tcp", cfg.GRPCServer.Address)
+	if err != nil {
+		logger.Error("failed to listen for grpc", sl.Err(err))
+		os.Exit(1)
+	}
+
+	go func() {
+		logger.Info("starting grpc server", slog.String("address", cfg.GRPCServer.Address))
+
+		if err := grpcSrv.Serve(listener); err != nil {
+			logger.Error("failed to start grpc server", sl.Err(err))
+			os.Exit(1)
+		}
+	}()
+
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	<-stop.Done()
+
+	logger.Info("stopping server")
+
+	// Fail readiness first so no new traffic is routed here while draining.
+	application.Health.Drain()
+	grpcHealth.Shutdown()
+	time.Sleep(cfg.HTTPServer.DrainDelay)
+
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancelShutdown()
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcStopped)
+	}()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("failed to drain requests", sl.Err(err))
+	}
+
+	// Calls still running when the shutdown timeout is up, such as long
+	// streams, are cut off.
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		logger.Error("failed to drain grpc calls", sl.Err(ctx.Err()))
+		grpcSrv.Stop()
+	}
+
+	if err := application.Stop(); err != nil {
+		logger.Error("failed to close storage", sl.Err(err))
+	}
+
+	logger.Info("server stopped")
 }
 
-func setupLogger(env string) *slog.Logger {
-	var log *slog.Logger
+// setupLogger builds the logger of env. level was validated by
+// config.MustLoad.
+func setupLogger(env string, level string) *slog.Logger {
+	var lvl slog.Level
+	_ = lvl.UnmarshalText([]byte(level))
 
-	switch env {
-	case envLocal:
-		log = slog.New(
-			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
-		)
+	opts := &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: sl.Redact,
 	}
 
-	return log
+	switch env {
+	case config.EnvLocal, config.EnvDev:
+		return slog.New(slog.NewTextHandler(os.Stdout, opts))
+	default:
+		opts.AddSource = true
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	}
 }
\ No newline at end of file
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/client"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/requests"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/responses"
+)
+
+func runCreate(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("create", "")
+
+	var request requests.CreateSubscriptionRequest
+	subscriptionFlags(flags, (*requests.UpdateSubscriptionRequest)(&request))
+
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return usageError(flags, "create takes no arguments")
+	}
+
+	callCtx, cancel := c.call(ctx)
+	defer cancel()
+
+	resp, err := c.client.Create(callCtx, request)
+	if err != nil {
+		return err
+	}
+
+	return c.print(resp, subscriptionsTable(createdSubscription(resp)))
+}
+
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("get", "ID")
+
+	id, err := parseWithID(flags, args)
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancel := c.call(ctx)
+	defer cancel()
+
+	resp, err := c.client.Get(callCtx, id)
+	if err != nil {
+		return err
+	}
+
+	return c.print(resp, subscriptionsTable(resp))
+}
+
+
+func runList(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("list", "")
+
+	var request requests.ListSubscriptionsRequest
+	flags.StringVar(&request.UserID, "user-id", "", "only subscriptions of this user")
+	flags.StringVar(&request.ServiceName, "service-name", "", "only subscriptions of this service")
+	flags.StringVar(&request.Currency, "currency", "", "only subscriptions billed in this currency")
+	flags.StringVar(&request.StartDateFrom, "start-from", "", "only subscriptions starting in or after this MM-YYYY month")
+	flags.StringVar(&request.StartDateTo, "start-to", "", "only subscriptions starting in or before this MM-YYYY month")
+	flags.Func("min-price", "lowest price in minor units", int64Flag(&request.MinPrice))
+	flags.Func("max-price", "highest price in minor units", int64Flag(&request.MaxPrice))
+	flags.StringVar(&request.Sort, "sort", "", "id, price or start_date, prefixed with - for descending order")
+	flags.IntVar(&request.Limit, "limit", 0, "page size")
+	flags.IntVar(&request.Offset, "offset", 0, "subscriptions to skip")
+	flags.BoolVar(&request.IncludeDeleted, "include-deleted", false, "also list deleted subscriptions")
+	flags.BoolVar(&request.IncludeTotal, "total", false, "also count the subscriptions on all pages")
+	all := flags.Bool("all", false, "follow the pages to the last one")
+
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return usageError(flags, "list takes no arguments")
+	}
+
+	// Every page is a call of its own, so --timeout applies to each.
+	list := func() (responses.ListSubscriptionsResponse, error) {
+		callCtx, cancel := c.call(ctx)
+		defer cancel()
+
+		return c.client.List(callCtx, request)
+	}
+
+	resp, err := list()
+	if err != nil {
+		return err
+	}
+
+	for *all && resp.NextCursor != "" {
+		request.Cursor = resp.NextCursor
+
+		page, err := list()
+		if err != nil {
+			return err
+		}
+
+		resp.Subscriptions = append(resp.Subscriptions, page.Subscriptions...)
+		resp.Total = page.Total
+		resp.NextCursor = page.NextCursor
+	}
+
+	return c.print(resp, subscriptionsTable(resp.Subscriptions...))
+}
+
+
+func runUpdate(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("update", "ID")
+
+	var request requests.UpdateSubscriptionRequest
+	subscriptionFlags(flags, &request)
+	versions := newVersionFlags(flags)
+
+	id, err := parseWithID(flags, args)
+	if err != nil {
+		return err
+	}
+
+	version, err := versions.get(flags)
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancel := c.call(ctx)
+	defer cancel()
+
+	resp, err := c.client.Update(callCtx, id, version, request)
+	if err != nil {
+		return err
+	}
+
+	return c.print(resp, subscriptionsTable(createdSubscription(responses.CreateSubscriptionResponse(resp))))
+}
+
+
+func runDelete(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("delete", "ID")
+	versions := newVersionFlags(flags)
+
+	id, err := parseWithID(flags, args)
+	if err != nil {
+		return err
+	}
+
+	version, err := versions.get(flags)
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancel := c.call(ctx)
+	defer cancel()
+
+	resp, err := c.client.Delete(callCtx, id, version)
+	if err != nil {
+		return err
+	}
+
+	return c.print(resp, table{
+		header: []string{"id", "message"},
+		rows:   [][]string{{strconv.FormatInt(resp.Id, 10), resp.Message}},
+	})
+}
+
+
+func runSum(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("sum", "")
+
+	var request requests.SumSubscriptionRequest
+	flags.StringVar(&request.StartDate, "start", "", "first MM-YYYY month of the period")
+	flags.StringVar(&request.EndDate, "end", "", "last MM-YYYY month of the period")
+	flags.StringVar(&request.UserID, "user-id", "", "only subscriptions of this user")
+	flags.StringVar(&request.ServiceName, "service-name", "", "only subscriptions of this service")
+	flags.BoolVar(&request.Detail, "detail", false, "also show the cost of every subscription")
+	flags.StringVar(&request.ConvertTo, "convert-to", "", "also convert the totals to this currency")
+
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return usageError(flags, "sum takes no arguments")
+	}
+
+	callCtx, cancel := c.call(ctx)
+	defer cancel()
+
+	resp, err := c.client.Sum(callCtx, request)
+	if err != nil {
+		return err
+	}
+
+	tables := []table{totalsTable(resp.Totals, resp.Converted)}
+
+	if request.Detail {
+		detail := table{header: []string{"id", "service_name", "user_id", "price", "months", "cost", "currency"}}
+		for _, cost := range resp.Subscriptions {
+			detail.rows = append(detail.rows, []string{
+				strconv.FormatInt(cost.Id, 10),
+				cost.ServiceName,
+				cost.UserID,
+				formatAmount(cost.Price),
+				strconv.FormatInt(cost.Months, 10),
+				formatAmount(cost.Cost),
+				cost.Cost.Currency,
+			})
+		}
+
+		tables = append(tables, detail)
+	}
+
+	return c.print(resp, tables...)
+}
+
+
+func runImport(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("import", "[FILE]")
+	format := flags.String("format", "", "csv or ndjson (default from the file extension, else csv)")
+
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 1 {
+		return usageError(flags, "import takes at most one file")
+	}
+
+	body := c.stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		body = file
+
+		if *format == "" && (filepath.Ext(path) == ".ndjson" || filepath.Ext(path) == ".jsonl") {
+			*format = client.FormatNDJSON
+		}
+	}
+
+	if *format == "" {
+		*format = client.FormatCSV
+	}
+
+	resp, err := c.client.Import(ctx, *format, body)
+	if err != nil {
+		return err
+	}
+
+	tables := []table{{
+		header: []string{"imported", "failed"},
+		rows:   [][]string{{strconv.Itoa(resp.Imported), strconv.Itoa(resp.Failed)}},
+	}}
+
+	if len(resp.Errors) > 0 {
+		failures := table{header: []string{"line", "error"}}
+		for _, lineErr := range resp.Errors {
+			failures.rows = append(failures.rows, []string{strconv.Itoa(lineErr.Line), lineErr.Error})
+		}
+
+		tables = append(tables, failures)
+	}
+
+	return c.print(resp, tables...)
+}
+
+
+// runExport writes the export as the server formats it, which import reads
+// back, so --output doesn't apply.
+func runExport(ctx context.Context, c *cli, args []string) error {
+	flags := newFlagSet("export", "")
+	format := flags.String("format", client.FormatCSV, "csv or ndjson")
+	path := flags.String("file", "", "write to this file instead of stdout")
+
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return usageError(flags, "export takes no arguments")
+	}
+
+	if *path == "" {
+		return c.client.Export(ctx, *format, c.stdout)
+	}
+
+	file, err := os.Create(*path)
+	if err != nil {
+		return err
+	}
+
+	if err := c.client.Export(ctx, *format, file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+
+// subscriptionFlags fills request from the flags of create and update. Every
+// field is sent as given, the server reports what is missing or malformed.
+func subscriptionFlags(flags *flag.FlagSet, request *requests.UpdateSubscriptionRequest) {
+	flags.StringVar(&request.ServiceName, "service-name", "", "name of the service")
+	flags.Int64Var(&request.Price.Amount, "price", 0, "monthly price in minor units, e.g. 49900 for 499 RUB")
+	flags.StringVar(&request.Price.Currency, "currency", "", "ISO 4217 currency of the price")
+	flags.StringVar(&request.UserID, "user-id", "", "UUID of the subscribed user")
+	flags.StringVar(&request.StartDate, "start", "", "first billed MM-YYYY month")
+	flags.StringVar(&request.EndDate, "end", "", "last billed MM-YYYY month, empty for open-ended")
+}
+
+
+// versionFlags are the flags naming the version a subscription must still be
+// at for update and delete to change it, as shown by get. One of them must be
+// given, so nothing is overwritten unchecked by accident.
+type versionFlags struct {
+	version int64
+	force   bool
+}
+
+
+func newVersionFlags(flags *flag.FlagSet) *versionFlags {
+	v := &versionFlags{}
+	flags.Int64Var(&v.version, "version", 0, "fail unless the subscription is still at this version (required unless --force)")
+	flags.BoolVar(&v.force, "force", false, "change the subscription whatever its version")
+	return v
+}
+
+
+// get returns the version to send, 0 for any with --force.
+func (v *versionFlags) get(flags *flag.FlagSet) (int64, error) {
+	switch {
+	case v.force && v.version != 0:
+		return 0, usageError(flags, "--version and --force exclude each other")
+	case v.force:
+		return 0, nil
+	case v.version <= 0:
+		return 0, usageError(flags, "--version is required, or --force to ignore the version")
+	}
+
+	return v.version, nil
+}
+
+
+func newFlagSet(name string, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: subsctl %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+
+// parse parses the flags of a command. The flag package has already reported
+// what is wrong.
+func parse(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+
+// parseWithID parses the flags of a command taking a subscription ID, which
+// may come before or after them.
+func parseWithID(flags *flag.FlagSet, args []string) (int64, error) {
+	var arg string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		arg, args = args[0], args[1:]
+	}
+
+	if err := parse(flags, args); err != nil {
+		return 0, err
+	}
+
+	rest := flags.Args()
+	if arg == "" && len(rest) > 0 {
+		arg, rest = rest[0], rest[1:]
+	}
+
+	if arg == "" || len(rest) != 0 {
+		return 0, usageError(flags, "expected exactly one subscription ID")
+	}
+
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, usageError(flags, fmt.Sprintf("invalid subscription ID %q", arg))
+	}
+
+	return id, nil
+}
+
+
+func usageError(flags *flag.FlagSet, msg string) error {
+	fmt.Fprintln(flags.Output(), msg)
+	flags.Usage()
+	return errUsage
+}
+
+
+// int64Flag sets *p for an optional numeric flag, which stays nil unless
+// given.
+func int64Flag(p **int64) func(string) error {
+	return func(s string) error {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = &n
+		return nil
+	}
+}
+
+
+
+// createdSubscription is the subscription a create or update answered with.
+func createdSubscription(resp responses.CreateSubscriptionResponse) models.Subscription {
+	return models.Subscription{
+		Id:          resp.Id,
+		ServiceName: resp.ServiceName,
+		Price:       resp.Price,
+		UserID:      resp.UserID,
+		StartDate:   resp.StartDate,
+		EndDate:     resp.EndDate,
+		Version:     resp.Version,
+	}
+}
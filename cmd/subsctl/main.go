@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/client"
+)
+
+const usage = `usage: subsctl [flags] command [command flags] [args]
+
+Calls the subscriptions REST API. Commands:
+
+  create                create a subscription
+  get ID                show a subscription
+  list                  list subscriptions matching filters
+  update ID             replace every field of a subscription, at --version
+  delete ID             delete a subscription, at --version
+  sum                   cost of subscriptions over a period
+  import [FILE]         import subscriptions from FILE or stdin (admin)
+  export                export every subscription to stdout (admin)
+
+Run "subsctl command -h" for the flags of a command. Flags:
+
+  --url URL             API address, or SUBSCTL_URL (default http://localhost:8080)
+  --api-key KEY         API key, or SUBSCTL_API_KEY
+  --token JWT           bearer token, or SUBSCTL_TOKEN, used without an API key
+  -o, --output FORMAT   table (default), json or csv, or SUBSCTL_OUTPUT
+  --timeout DURATION    limit of every call but import and export (default 30s),
+                        0 for none
+`
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+const defaultURL = "http://localhost:8080"
+
+// errUsage is returned for a malformed command line after the problem was
+// reported, so main exits with 2.
+var errUsage = errors.New("usage")
+
+
+// cli is what every command gets: the API client and where to write results.
+type cli struct {
+	client  *client.Client
+	output  string
+	stdout  io.Writer
+	stdin   io.Reader
+	// timeout limits the calls of the commands that don't stream.
+	timeout time.Duration
+}
+
+
+// call returns the context of a single call of a command that doesn't stream,
+// limited by --timeout.
+func (c *cli) call(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+
+// command runs with the arguments following its name.
+type command func(ctx context.Context, c *cli, args []string) error
+
+var commands = map[string]command{
+	"create": runCreate,
+	"get":    runGet,
+	"list":   runList,
+	"update": runUpdate,
+	"delete": runDelete,
+	"sum":    runSum,
+	"import": runImport,
+	"export": runExport,
+}
+
+
+func main() {
+	flags := flag.NewFlagSet("subsctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+
+	var (
+		baseURL = flags.String("url", envOr("SUBSCTL_URL", defaultURL), "")
+		apiKey  = flags.String("api-key", os.Getenv("SUBSCTL_API_KEY"), "")
+		token   = flags.String("token", os.Getenv("SUBSCTL_TOKEN"), "")
+		output  = flags.String("output", envOr("SUBSCTL_OUTPUT", outputTable), "")
+		timeout = flags.Duration("timeout", 30*time.Second, "")
+	)
+	flags.StringVar(output, "o", *output, "")
+
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	run, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flags.Arg(0))
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch *output {
+	case outputTable, outputJSON, outputCSV:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		os.Exit(2)
+	}
+
+	apiClient, err := client.New(*baseURL, *apiKey, *token)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	err = run(ctx, &cli{
+		client:  apiClient,
+		output:  *output,
+		stdout:  os.Stdout,
+		stdin:   os.Stdin,
+		timeout: *timeout,
+	}, flags.Args()[1:])
+
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+)
+
+// table is a result as rows of cells, for the table and csv outputs.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+
+// print writes resp as indented JSON, or tables one after another, separated
+// by an empty line. Table headers are upper-cased, CSV headers are not.
+func (c *cli) print(resp any, tables ...table) error {
+	switch c.output {
+	case outputJSON:
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(resp)
+	case outputCSV:
+		w := csv.NewWriter(c.stdout)
+		for i, t := range tables {
+			if i > 0 {
+				fmt.Fprintln(c.stdout)
+			}
+
+			w.Write(t.header)
+			w.WriteAll(t.rows)
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		for i, t := range tables {
+			if i > 0 {
+				fmt.Fprintln(c.stdout)
+			}
+
+			w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, strings.ToUpper(strings.Join(t.header, "\t")))
+			for _, row := range t.rows {
+				fmt.Fprintln(w, strings.Join(row, "\t"))
+			}
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+
+func subscriptionsTable(subscriptions ...models.Subscription) table {
+	t := table{header: []string{"id", "service_name", "price", "currency", "user_id", "start_date", "end_date", "version", "deleted"}}
+
+	for _, subscription := range subscriptions {
+		var endDate string
+		if subscription.EndDate != nil {
+			endDate = subscription.EndDate.String()
+		}
+
+		t.rows = append(t.rows, []string{
+			strconv.FormatInt(subscription.Id, 10),
+			subscription.ServiceName,
+			formatAmount(subscription.Price),
+			subscription.Price.Currency,
+			subscription.UserID,
+			subscription.StartDate.String(),
+			endDate,
+			strconv.FormatInt(subscription.Version, 10),
+			strconv.FormatBool(subscription.DeletedAt != nil),
+		})
+	}
+
+	return t
+}
+
+
+func totalsTable(totals []models.Money, converted *models.Money) table {
+	t := table{header: []string{"currency", "total"}}
+
+	for _, total := range totals {
+		t.rows = append(t.rows, []string{total.Currency, formatAmount(total)})
+	}
+
+	if converted != nil {
+		t.rows = append(t.rows, []string{converted.Currency + " (converted)", formatAmount(*converted)})
+	}
+
+	return t
+}
+
+
+// formatAmount writes the amount of money in major units, e.g. "499.00" for
+// 49900 RUB, as people read prices.
+func formatAmount(money models.Money) string {
+	digits := models.MinorUnits(money.Currency)
+
+	amount := strconv.FormatInt(money.Amount, 10)
+
+	sign := ""
+	if amount[0] == '-' {
+		sign, amount = "-", amount[1:]
+	}
+
+	if digits == 0 {
+		return sign + amount
+	}
+
+	if len(amount) <= digits {
+		amount = strings.Repeat("0", digits-len(amount)+1) + amount
+	}
+
+	return sign + amount[:len(amount)-digits] + "." + amount[len(amount)-digits:]
+}
@@ -2,22 +2,87 @@ package app
 
 import (
 	"log/slog"
+	"time"
 
+	"github.com/BahadirAhmedov/data-aggregation/internal/config"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
 	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/handlers"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/idempotency"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/money"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage/memory"
 	"github.com/BahadirAhmedov/data-aggregation/internal/storage/postgre"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
+type App struct {
+	// Subscriptions is the service behind both the HTTP handlers and the
+	// gRPC API.
+	Subscriptions   *service.Subscriptions
+	Handlers        *handlers.Subscription
+	Health          *handlers.Health
+	// IdempotencyKeys keep the responses of requests sent with an
+	// Idempotency-Key, in the same storage as the subscriptions.
+	IdempotencyKeys idempotency.Store
+	storage         Storage
+}
+
+// Storage is a subscriptions backend that holds resources to release on
+// shutdown and reports its health to readiness probes.
+type Storage interface {
+	service.Storage
+	handlers.HealthChecker
+	idempotency.Store
+	Close() error
+}
 
 func New(
 	log *slog.Logger,
-	host string, 
-	port int, 
-	user string, 
-	password string, 
-	dbname string,
-) (*handlers.Subscription) {
-	storage := postgre.New(host, port, user, password, dbname)
-	
-	SubscriptionHandlers := handlers.New(storage)
-	return SubscriptionHandlers
-}
\ No newline at end of file
+	storageType string,
+	credentials config.StorageCredentials,
+	exchangeRates config.ExchangeRates,
+	readinessTimeout time.Duration,
+	reg prometheus.Registerer,
+) *App {
+	rates, err := money.NewTable(exchangeRates.Base, exchangeRates.Rates)
+	if err != nil {
+		panic(err)
+	}
+
+	var storage Storage
+
+	switch storageType {
+	case config.StorageMemory:
+		log.Warn("using in-memory storage, data will be lost on exit")
+		storage = memory.New()
+	default:
+		pg := postgre.New(credentials.ConnectionURL(), credentials.Timeout, postgre.Pool{
+			MaxOpenConns:    credentials.Pool.MaxOpenConns,
+			MaxIdleConns:    credentials.Pool.MaxIdleConns,
+			ConnMaxLifetime: credentials.Pool.ConnMaxLifetime,
+		})
+		log.Info("connected to postgres", slog.String("host", credentials.Host), slog.String("dbname", credentials.DbName))
+		reg.MustRegister(collectors.NewDBStatsCollector(pg.DB(), credentials.DbName))
+		storage = pg
+	}
+
+	// Scrapes read the storage directly, so they don't show up in the
+	// storage metrics of requests.
+	reg.MustRegister(newBusinessCollector(service.New(storage, rates)))
+
+	subscriptions := service.New(newMeteredStorage(storage, reg), rates)
+
+	return &App{
+		Subscriptions:   subscriptions,
+		Handlers:        handlers.New(subscriptions),
+		Health:          handlers.NewHealth(storage, readinessTimeout),
+		IdempotencyKeys: storage,
+		storage:         storage,
+	}
+}
+
+// Stop releases the storage. Call it only after the servers have drained,
+// so in-flight requests don't lose their connections.
+func (a *App) Stop() error {
+	return a.storage.Close()
+}
@@ -0,0 +1,267 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectTimeout bounds the storage queries run on every scrape.
+const collectTimeout = 5 * time.Second
+
+// answers are errors storage returns when it worked as it should and the
+// request can't be done, so they aren't counted as storage errors.
+var answers = []error{
+	storage.ErrSubscriptionNotFound,
+	storage.ErrSubscriptionExists,
+	storage.ErrVersionMismatch,
+	storage.ErrBatchAborted,
+}
+
+
+// meteredStorage observes the latency and the errors of every operation of
+// the service.Storage it wraps.
+type meteredStorage struct {
+	service.Storage
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+
+func newMeteredStorage(s service.Storage, reg prometheus.Registerer) *meteredStorage {
+	m := &meteredStorage{
+		Storage: s,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "storage_operation_duration_seconds",
+			Help:    "Time taken by storage operations, by operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_operation_errors_total",
+			Help: "Storage operations that failed, by operation. Missing subscriptions, duplicates, version conflicts and rolled back batches aren't failures.",
+		}, []string{"operation"}),
+	}
+
+	reg.MustRegister(m.duration, m.errors)
+
+	return m
+}
+
+
+// observe records one call of operation that started at start. Use it as
+// defer m.observe("Read", time.Now(), &err).
+func (m *meteredStorage) observe(operation string, start time.Time, err *error) {
+	m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+	if failed(*err) {
+		m.errors.WithLabelValues(operation).Inc()
+	}
+}
+
+
+// observeWithCallback is observe for operations that call back into the
+// caller. callbackErr is the last error of the callback, which storage only
+// passes on.
+func (m *meteredStorage) observeWithCallback(operation string, start time.Time, err *error, callbackErr *error) {
+	counted := *err
+	if *callbackErr != nil && errors.Is(counted, *callbackErr) {
+		counted = nil
+	}
+
+	m.observe(operation, start, &counted)
+}
+
+
+func failed(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, answer := range answers {
+		if errors.Is(err, answer) {
+			return false
+		}
+	}
+
+	return true
+}
+
+
+func (m *meteredStorage) Create(ctx context.Context, subscription models.Subscription) (id int64, err error) {
+	defer m.observe("Create", time.Now(), &err)
+	return m.Storage.Create(ctx, subscription)
+}
+
+
+func (m *meteredStorage) Read(ctx context.Context, id int64) (subscription models.Subscription, err error) {
+	defer m.observe("Read", time.Now(), &err)
+	return m.Storage.Read(ctx, id)
+}
+
+
+func (m *meteredStorage) Update(ctx context.Context, subscription models.Subscription) (stored models.Subscription, err error) {
+	defer m.observe("Update", time.Now(), &err)
+	return m.Storage.Update(ctx, subscription)
+}
+
+
+func (m *meteredStorage) Patch(ctx context.Context, id int64, version int64, patch models.Patch, check func(models.Subscription) error) (subscription models.Subscription, err error) {
+	var rejected error
+	defer m.observeWithCallback("Patch", time.Now(), &err, &rejected)
+
+	return m.Storage.Patch(ctx, id, version, patch, func(subscription models.Subscription) error {
+		rejected = check(subscription)
+		return rejected
+	})
+}
+
+
+func (m *meteredStorage) Delete(ctx context.Context, id int64, version int64) (err error) {
+	defer m.observe("Delete", time.Now(), &err)
+	return m.Storage.Delete(ctx, id, version)
+}
+
+
+func (m *meteredStorage) Restore(ctx context.Context, id int64) (subscription models.Subscription, err error) {
+	defer m.observe("Restore", time.Now(), &err)
+	return m.Storage.Restore(ctx, id)
+}
+
+
+func (m *meteredStorage) History(ctx context.Context, id int64) (changes []models.SubscriptionChange, err error) {
+	defer m.observe("History", time.Now(), &err)
+	return m.Storage.History(ctx, id)
+}
+
+
+func (m *meteredStorage) Owner(ctx context.Context, id int64) (owner string, err error) {
+	defer m.observe("Owner", time.Now(), &err)
+	return m.Storage.Owner(ctx, id)
+}
+
+
+func (m *meteredStorage) List(ctx context.Context, filter models.ListFilter) (subscriptions []models.Subscription, total int64, err error) {
+	defer m.observe("List", time.Now(), &err)
+	return m.Storage.List(ctx, filter)
+}
+
+
+func (m *meteredStorage) Sum(ctx context.Context, filter models.CostFilter, detail bool) (result models.SumResult, err error) {
+	defer m.observe("Sum", time.Now(), &err)
+	return m.Storage.Sum(ctx, filter, detail)
+}
+
+
+func (m *meteredStorage) Report(ctx context.Context, filter models.CostFilter, groupBy []string) (groups []models.CostGroup, err error) {
+	defer m.observe("Report", time.Now(), &err)
+	return m.Storage.Report(ctx, filter, groupBy)
+}
+
+
+func (m *meteredStorage) Batch(ctx context.Context, operations []models.BatchOperation, atomic bool) (results []storage.BatchResult, err error) {
+	defer m.observe("Batch", time.Now(), &err)
+	return m.Storage.Batch(ctx, operations, atomic)
+}
+
+
+func (m *meteredStorage) Export(ctx context.Context, fn func(models.Subscription) error) (err error) {
+	var fnErr error
+	defer m.observeWithCallback("Export", time.Now(), &err, &fnErr)
+
+	return m.Storage.Export(ctx, func(subscription models.Subscription) error {
+		fnErr = fn(subscription)
+		return fnErr
+	})
+}
+
+
+// businessCollector reports the subscriptions active in the current month and
+// the revenue they bring per service. It runs one report, a single query, on
+// every scrape.
+type businessCollector struct {
+	subscriptions *service.Subscriptions
+
+	active  *prometheus.Desc
+	revenue *prometheus.Desc
+	up      *prometheus.Desc
+}
+
+
+func newBusinessCollector(subscriptions *service.Subscriptions) *businessCollector {
+	return &businessCollector{
+		subscriptions: subscriptions,
+		active: prometheus.NewDesc(
+			"subscriptions_active",
+			"Subscriptions active in the current month.",
+			nil, nil,
+		),
+		revenue: prometheus.NewDesc(
+			"subscriptions_monthly_recurring_revenue",
+			"Revenue of the subscriptions active in the current month, in major units of currency.",
+			[]string{"service_name", "currency"}, nil,
+		),
+		up: prometheus.NewDesc(
+			"subscriptions_business_metrics_up",
+			"Whether the business metrics could be read from the storage on the last scrape.",
+			nil, nil,
+		),
+	}
+}
+
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.revenue
+	ch <- c.up
+}
+
+
+// Collect reports 0 for up instead of failing the scrape, so the HTTP and
+// storage metrics still come through while the database is down.
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	// The gauges cover every user.
+	ctx = service.WithPrincipal(ctx, models.Principal{Subject: "metrics", Role: models.RoleAdmin})
+
+	month := models.MonthOf(time.Now())
+	filter := models.CostFilter{Period: models.Period{From: month, To: month}}
+
+	report, err := c.subscriptions.Report(ctx, filter, []string{models.GroupByServiceName})
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+
+	// Every subscription is in exactly one group, that of its service and
+	// currency.
+	var active int64
+
+	for _, group := range report.Groups {
+		active += group.Count
+
+		ch <- prometheus.MustNewConstMetric(c.revenue, prometheus.GaugeValue,
+			majorUnits(group.Total), group.ServiceName, group.Total.Currency)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(active))
+}
+
+
+func majorUnits(amount models.Money) float64 {
+	value := new(big.Rat).SetFrac(
+		big.NewInt(amount.Amount),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(models.MinorUnits(amount.Currency))), nil),
+	)
+
+	f, _ := value.Float64()
+	return f
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func errorCount(t *testing.T, m *meteredStorage, operation string) float64 {
+	t.Helper()
+
+	var metric dto.Metric
+	if err := m.errors.WithLabelValues(operation).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+
+	return metric.GetCounter().GetValue()
+}
+
+func TestMeteredStorageErrors(t *testing.T) {
+	m := newMeteredStorage(memory.New(), prometheus.NewRegistry())
+	ctx := t.Context()
+
+	subscription := models.Subscription{
+		ServiceName: "Yandex Plus",
+		Price:       models.Money{Amount: 400, Currency: "RUB"},
+		UserID:      "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		StartDate:   models.NewMonth(2025, 7),
+	}
+
+	id, err := m.Create(ctx, subscription)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// None of these is a storage failure.
+	m.Create(ctx, subscription)
+	m.Read(ctx, id+1)
+	m.Delete(ctx, id, id+1)
+	rejected := errors.New("rejected")
+	m.Patch(ctx, id, 0, models.Patch{}, func(models.Subscription) error { return rejected })
+	m.Export(ctx, func(models.Subscription) error { return rejected })
+
+	for _, operation := range []string{"Create", "Read", "Delete", "Patch", "Export"} {
+		if got := errorCount(t, m, operation); got != 0 {
+			t.Errorf("%s errors = %v, want 0", operation, got)
+		}
+	}
+
+	failure := errors.New("connection reset")
+	m.observe("Read", time.Now(), &failure)
+
+	if got := errorCount(t, m, "Read"); got != 1 {
+		t.Errorf("Read errors after a failure = %v, want 1", got)
+	}
+}
@@ -1,50 +1,246 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 )
 
+const (
+	StoragePostgres = "postgres"
+	StorageMemory = "memory"
+
+	EnvLocal = "local"
+	EnvDev = "dev"
+	EnvProd = "prod"
+)
+
 type Config struct{
-	Env string `yaml:"env" env-default:"local"`
+	// Env picks the logging profile: "local" and "dev" log text, "prod" logs
+	// JSON.
+	Env string `yaml:"env" env:"ENV" env-default:"local"`
+	// LogLevel is the lowest level logged: debug, info, warn or error.
+	LogLevel string `yaml:"log-level" env:"LOG_LEVEL" env-default:"info"`
+	// StorageType selects the subscriptions backend: "postgres" or "memory".
+	// The in-memory backend needs no database and loses its data on exit.
+	StorageType string `yaml:"storage-type" env:"STORAGE_TYPE" env-default:"postgres"`
 	Storage StorageCredentials `yaml:"storage-credentials"`
+	HTTPServer HTTPServer `yaml:"http-server"`
+	GRPCServer GRPCServer `yaml:"grpc-server"`
+	ExchangeRates ExchangeRates `yaml:"exchange-rates"`
+	Auth Auth `yaml:"auth"`
+	Idempotency Idempotency `yaml:"idempotency"`
 	//TODO: Define config fields
 }
 
+type HTTPServer struct{
+	Address string `yaml:"address" env:"HTTP_ADDRESS" env-default:":8080"`
+	ReadTimeout time.Duration `yaml:"read-timeout" env:"HTTP_READ_TIMEOUT" env-default:"5s"`
+	WriteTimeout time.Duration `yaml:"write-timeout" env:"HTTP_WRITE_TIMEOUT" env-default:"10s"`
+	IdleTimeout time.Duration `yaml:"idle-timeout" env:"HTTP_IDLE_TIMEOUT" env-default:"60s"`
+	// ShutdownTimeout is how long in-flight requests get to finish after
+	// SIGINT/SIGTERM before the server is closed anyway.
+	ShutdownTimeout time.Duration `yaml:"shutdown-timeout" env:"HTTP_SHUTDOWN_TIMEOUT" env-default:"15s"`
+	// DrainDelay is how long /readyz answers 503 after SIGINT/SIGTERM before
+	// the server stops accepting connections, so probes notice first.
+	DrainDelay time.Duration `yaml:"drain-delay" env:"HTTP_DRAIN_DELAY" env-default:"0s"`
+	// ReadinessTimeout caps the storage check of /readyz.
+	ReadinessTimeout time.Duration `yaml:"readiness-timeout" env:"HTTP_READINESS_TIMEOUT" env-default:"2s"`
+}
+
+// GRPCServer serves the gRPC API next to the HTTP one. It shares the shutdown
+// timeout and drain delay of HTTPServer.
+type GRPCServer struct{
+	Address string `yaml:"address" env:"GRPC_ADDRESS" env-default:":9090"`
+}
+
+// Auth holds the credentials accepted by the API. JWTSecret signs bearer tokens
+// with HMAC; leave it empty to accept API keys only. AUTH_API_KEYS replaces
+// the API keys of the config file with "key:subject:role,...".
+type Auth struct{
+	JWTSecret string `yaml:"jwt-secret" env:"AUTH_JWT_SECRET"`
+	APIKeys APIKeys `yaml:"api-keys" env:"AUTH_API_KEYS"`
+}
+
+// APIKey authenticates whoever sends Key in the X-API-Key header as Subject
+// with Role, either "admin" or "user".
+type APIKey struct{
+	Key string `yaml:"key"`
+	Subject string `yaml:"subject"`
+	Role string `yaml:"role"`
+}
+
+// APIKeys are the API keys accepted, in no particular order.
+type APIKeys []APIKey
+
+// SetValue parses the "key:subject:role,..." form of AUTH_API_KEYS.
+func (k *APIKeys) SetValue(value string) error {
+	var keys APIKeys
+
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("api key %q is not key:subject:role", entry)
+		}
+
+		keys = append(keys, APIKey{Key: parts[0], Subject: parts[1], Role: parts[2]})
+	}
+
+	*k = keys
+	return nil
+}
+
+// Idempotency configures the Idempotency-Key header of the create, batch and
+// import endpoints. TTL is how long a key and its response are kept, so how
+// long a client may retry.
+type Idempotency struct{
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`
+}
+
+// ExchangeRates prices currencies in Base for converted totals. Rates are
+// decimal strings, so with base RUB an entry USD: "92.5" means 1 USD is worth
+// 92.5 RUB. Totals can't be converted to or from a currency missing here.
+// EXCHANGE_RATES takes the rates as "USD:92.5,EUR:100.2".
+type ExchangeRates struct{
+	Base string `yaml:"base" env:"EXCHANGE_RATES_BASE" env-default:"RUB"`
+	Rates map[string]string `yaml:"rates" env:"EXCHANGE_RATES"`
+}
+
+// StorageCredentials locate the Postgres database. DSN, when set, is used as
+// is and the connection fields next to it are ignored; it must be a
+// postgres:// URL, as the migrator only understands those.
 type StorageCredentials struct{
-	Host string `yaml:"host"`
-	Port int `yaml:"port"`
-	User string `yaml:"user"`
-	Password string `yaml:"password"`
-	DbName string `yaml:"dbname"`
+	DSN string `yaml:"dsn" env:"STORAGE_DSN"`
+	Host string `yaml:"host" env:"STORAGE_HOST"`
+	Port int `yaml:"port" env:"STORAGE_PORT" env-default:"5432"`
+	User string `yaml:"user" env:"STORAGE_USER"`
+	Password string `yaml:"password" env:"STORAGE_PASSWORD"`
+	DbName string `yaml:"dbname" env:"STORAGE_DBNAME"`
+	// SSLMode is a libpq sslmode: disable, require, verify-ca or verify-full.
+	// The certificate paths are only read by the verifying modes.
+	SSLMode string `yaml:"ssl-mode" env:"STORAGE_SSL_MODE" env-default:"disable"`
+	SSLRootCert string `yaml:"ssl-root-cert" env:"STORAGE_SSL_ROOT_CERT"`
+	SSLCert string `yaml:"ssl-cert" env:"STORAGE_SSL_CERT"`
+	SSLKey string `yaml:"ssl-key" env:"STORAGE_SSL_KEY"`
+	// Timeout caps how long a single storage call may run. It applies on top
+	// of the request context, so a client disconnect still cancels earlier.
+	Timeout time.Duration `yaml:"timeout" env:"STORAGE_TIMEOUT" env-default:"5s"`
+	Pool Pool `yaml:"pool"`
 }
 
-func MustLoad() (*Config) {
+// Pool tunes the database/sql connection pool. Zero MaxOpenConns means no
+// limit, zero ConnMaxLifetime keeps connections forever.
+type Pool struct{
+	MaxOpenConns int `yaml:"max-open-conns" env:"STORAGE_MAX_OPEN_CONNS" env-default:"20"`
+	MaxIdleConns int `yaml:"max-idle-conns" env:"STORAGE_MAX_IDLE_CONNS" env-default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn-max-lifetime" env:"STORAGE_CONN_MAX_LIFETIME" env-default:"30m"`
+}
 
-	err := godotenv.Load("local.env")
-	if err != nil {
-		fmt.Println(err)
-		log.Fatal("unable to load .env file values")		
+// ConnectionURL is the postgres:// URL of the credentials, understood by both
+// lib/pq and golang-migrate.
+func (c StorageCredentials) ConnectionURL() string {
+	if c.DSN != "" {
+		return c.DSN
 	}
 
-	// CONFIG_PATH=/app/local.yaml
-	configPath := os.Getenv("CONFIG_PATH")
-	fmt.Println(configPath)
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	for key, value := range map[string]string{
+		"sslrootcert": c.SSLRootCert,
+		"sslcert":     c.SSLCert,
+		"sslkey":      c.SSLKey,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
 
-	if configPath == "" {
-		log.Fatal("CONFIG_PATH is not set")
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     c.DbName,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// MustLoad reads the config file at configPath, or CONFIG_PATH when it is
+// empty, then lets environment variables override any field. Without a config file
+// the config comes from the environment alone. Variables from an optional
+// .env file, local.env unless ENV_FILE says otherwise, never override those
+// already set.
+func MustLoad(configPath string) (*Config) {
+
+	envFile := os.Getenv("ENV_FILE")
+	if envFile == "" {
+		envFile = "local.env"
 	}
-	
-	if _, err :=  os.Stat(configPath); os.IsNotExist(err){
-		log.Fatalf("config file does not exist: %s", configPath)
+
+	if err := godotenv.Load(envFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("cannot read %s: %s", envFile, err)
+	}
+
+	if configPath == "" {
+		configPath = os.Getenv("CONFIG_PATH")
 	}
 
 	var cfg Config
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil{
-		log.Fatalf("cannot read config: %s", err)
+
+	if configPath == "" {
+		if err := cleanenv.ReadEnv(&cfg); err != nil {
+			log.Fatalf("cannot read config from environment: %s", err)
+		}
+	} else {
+		if _, err :=  os.Stat(configPath); os.IsNotExist(err){
+			log.Fatalf("config file does not exist: %s", configPath)
+		}
+
+		if err := cleanenv.ReadConfig(configPath, &cfg); err != nil{
+			log.Fatalf("cannot read config: %s", err)
+		}
+	}
+
+	if cfg.Env != EnvLocal && cfg.Env != EnvDev && cfg.Env != EnvProd {
+		log.Fatalf("unknown env: %s", cfg.Env)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		log.Fatalf("unknown log level: %s", cfg.LogLevel)
+	}
+
+	if cfg.StorageType != StoragePostgres && cfg.StorageType != StorageMemory {
+		log.Fatalf("unknown storage type: %s", cfg.StorageType)
+	}
+
+	switch cfg.Storage.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		log.Fatalf("unknown ssl mode: %s", cfg.Storage.SSLMode)
+	}
+
+	for _, apiKey := range cfg.Auth.APIKeys {
+		if apiKey.Key == "" || apiKey.Subject == "" {
+			log.Fatal("api keys need a key and a subject")
+		}
+		if apiKey.Role != "admin" && apiKey.Role != "user" {
+			log.Fatalf("unknown role of api key for %s: %s", apiKey.Subject, apiKey.Role)
+		}
 	}
 
 	return &cfg
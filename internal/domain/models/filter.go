@@ -0,0 +1,98 @@
+package models
+
+const (
+	GroupByServiceName = "service_name"
+	GroupByUserID      = "user_id"
+	GroupByMonth       = "month"
+
+	SortByID        = "id"
+	SortByPrice     = "price"
+	SortByStartDate = "start_date"
+)
+
+
+// Period is the months from From to To, both included.
+type Period struct {
+	From Month
+	To   Month
+}
+
+
+// ListFilter selects a page of subscriptions. Empty fields and nil pointers
+// don't filter. Prices are compared by amount in minor units, so price bounds
+// only make sense together with Currency.
+type ListFilter struct {
+	UserID         string
+	ServiceName    string
+	Currency       string
+	StartFrom      *Month
+	StartTo        *Month
+	MinPrice       *int64
+	MaxPrice       *int64
+	IncludeDeleted bool
+
+	// SortBy is one of the SortBy constants, ties are broken by id.
+	SortBy string
+	Desc   bool
+	Limit  int
+	Offset int
+	// After starts the page right after this position in the sort order,
+	// before Offset is applied.
+	After *ListCursor
+	// CountTotal asks for the number of matching subscriptions on all pages,
+	// which costs a query of its own.
+	CountTotal bool
+}
+
+
+// ListCursor is a position in the order subscriptions are listed in: the
+// price, start date and id of the last subscription of a page. Only the field
+// sorted by and the id are compared.
+type ListCursor struct {
+	Price     int64
+	StartDate Month
+	ID        int64
+}
+
+
+// CursorOf is the position of subscription.
+func CursorOf(subscription Subscription) ListCursor {
+	return ListCursor{
+		Price:     subscription.Price.Amount,
+		StartDate: subscription.StartDate,
+		ID:        subscription.Id,
+	}
+}
+
+
+// ListPage is a page of subscriptions. Next is where the following page
+// starts, nil on the last one. Total is the number of matching subscriptions
+// on all pages, nil unless the filter asked for it.
+type ListPage struct {
+	Subscriptions []Subscription
+	Next          *ListCursor
+	Total         *int64
+}
+
+
+// CostFilter selects the subscriptions billed in Period, optionally only those
+// of one user or service.
+type CostFilter struct {
+	Period      Period
+	UserID      string
+	ServiceName string
+}
+
+
+// BatchOperation is one create, update or delete of a batch. Op is
+// ActionCreate, ActionUpdate or ActionDelete. ID is the subscription to update
+// or delete, Subscription the values to create or update it with. Version is
+// the version ID must still be at for the operation to run. Updates and
+// deletes need a Version unless Force is set, which runs them at any version.
+type BatchOperation struct {
+	Op           string
+	ID           int64
+	Version      int64
+	Force        bool
+	Subscription *Subscription
+}
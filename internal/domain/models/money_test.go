@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestIsCurrencyCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "RUB", want: true},
+		{in: "USD", want: true},
+		{in: "XCG", want: true},
+		{in: "XYZ", want: false},
+		{in: "usd", want: false},
+		{in: "US", want: false},
+		{in: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsCurrencyCode(tt.in); got != tt.want {
+			t.Errorf("IsCurrencyCode(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MonthLayout is the MM-YYYY format months are written in.
+const MonthLayout = "01-2006"
+
+var ErrInvalidMonth = errors.New("month must be in MM-YYYY format")
+
+
+// Month is a calendar month, the granularity of subscription dates. The zero
+// Month is no month at all. It is written as MM-YYYY in JSON.
+type Month struct {
+	year  int
+	month time.Month
+}
+
+
+func NewMonth(year int, month time.Month) Month {
+	return MonthOf(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC))
+}
+
+
+// MonthOf returns the month t falls in.
+func MonthOf(t time.Time) Month {
+	return Month{year: t.Year(), month: t.Month()}
+}
+
+
+func ParseMonth(s string) (Month, error) {
+	t, err := time.Parse(MonthLayout, s)
+	if err != nil {
+		return Month{}, fmt.Errorf("%w: %q", ErrInvalidMonth, s)
+	}
+	return MonthOf(t), nil
+}
+
+
+func (m Month) IsZero() bool {
+	return m == Month{}
+}
+
+
+// Time is midnight UTC on the first day of m.
+func (m Month) Time() time.Time {
+	return time.Date(m.year, m.month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+
+func (m Month) String() string {
+	if m.IsZero() {
+		return ""
+	}
+	return m.Time().Format(MonthLayout)
+}
+
+
+func (m Month) Before(other Month) bool {
+	return m.index() < other.index()
+}
+
+
+func (m Month) After(other Month) bool {
+	return m.index() > other.index()
+}
+
+
+// Compare returns -1, 0 or +1 as m is before, equal to or after other.
+func (m Month) Compare(other Month) int {
+	return cmp.Compare(m.index(), other.index())
+}
+
+
+// AddMonths returns the month n months after m, or before it for negative n.
+func (m Month) AddMonths(n int) Month {
+	return MonthOf(m.Time().AddDate(0, n, 0))
+}
+
+
+// MonthsUntil counts the months from m to end, both included. It is 0 when
+// end is before m.
+func (m Month) MonthsUntil(end Month) int64 {
+	return max(int64(end.index()-m.index()+1), 0)
+}
+
+
+func (m Month) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+
+func (m *Month) UnmarshalText(text []byte) error {
+	month, err := ParseMonth(string(text))
+	if err != nil {
+		return err
+	}
+	*m = month
+	return nil
+}
+
+
+// index numbers months consecutively, so months can be compared and
+// subtracted.
+func (m Month) index() int {
+	return m.year*12 + int(m.month) - 1
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseMonth(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Month
+		wantErr bool
+	}{
+		{in: "07-2025", want: NewMonth(2025, time.July)},
+		{in: "12-1999", want: NewMonth(1999, time.December)},
+		{in: "7-2025", wantErr: true},
+		{in: "13-2025", wantErr: true},
+		{in: "2025-07", wantErr: true},
+		{in: "07-2025-01", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMonth(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMonth) {
+					t.Fatalf("ParseMonth(%q) error = %v, want ErrInvalidMonth", tt.in, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseMonth(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMonth(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+
+func TestMonthCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      Month
+		other  Month
+		want   int
+		before bool
+		after  bool
+	}{
+		{name: "same month", m: NewMonth(2025, time.March), other: NewMonth(2025, time.March), want: 0},
+		{name: "earlier month", m: NewMonth(2025, time.February), other: NewMonth(2025, time.March), want: -1, before: true},
+		{name: "across a year", m: NewMonth(2024, time.December), other: NewMonth(2025, time.January), want: -1, before: true},
+		{name: "later year", m: NewMonth(2026, time.January), other: NewMonth(2025, time.December), want: 1, after: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Compare(tt.other); got != tt.want {
+				t.Errorf("Compare = %d, want %d", got, tt.want)
+			}
+			if got := tt.m.Before(tt.other); got != tt.before {
+				t.Errorf("Before = %v, want %v", got, tt.before)
+			}
+			if got := tt.m.After(tt.other); got != tt.after {
+				t.Errorf("After = %v, want %v", got, tt.after)
+			}
+		})
+	}
+}
+
+
+func TestMonthAddMonths(t *testing.T) {
+	tests := []struct {
+		m    Month
+		n    int
+		want Month
+	}{
+		{m: NewMonth(2025, time.January), n: 1, want: NewMonth(2025, time.February)},
+		{m: NewMonth(2025, time.November), n: 3, want: NewMonth(2026, time.February)},
+		{m: NewMonth(2025, time.January), n: -1, want: NewMonth(2024, time.December)},
+		{m: NewMonth(2025, time.June), n: 0, want: NewMonth(2025, time.June)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.AddMonths(tt.n); got != tt.want {
+			t.Errorf("%v.AddMonths(%d) = %v, want %v", tt.m, tt.n, got, tt.want)
+		}
+	}
+}
+
+
+func TestMonthsUntil(t *testing.T) {
+	tests := []struct {
+		name string
+		from Month
+		to   Month
+		want int64
+	}{
+		{name: "single month", from: NewMonth(2025, time.July), to: NewMonth(2025, time.July), want: 1},
+		{name: "within a year", from: NewMonth(2025, time.July), to: NewMonth(2025, time.December), want: 6},
+		{name: "across a year", from: NewMonth(2024, time.November), to: NewMonth(2025, time.February), want: 4},
+		{name: "end before start", from: NewMonth(2025, time.July), to: NewMonth(2025, time.June), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.MonthsUntil(tt.to); got != tt.want {
+				t.Errorf("MonthsUntil = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+
+func TestSubscriptionMonthsIn(t *testing.T) {
+	month := func(m time.Month, year int) *Month {
+		month := NewMonth(year, m)
+		return &month
+	}
+
+	period := Period{From: NewMonth(2025, time.January), To: NewMonth(2025, time.December)}
+
+	tests := []struct {
+		name  string
+		start Month
+		end   *Month
+		want  int64
+	}{
+		{name: "open-ended, started before", start: NewMonth(2024, time.June), want: 12},
+		{name: "open-ended, starts inside", start: NewMonth(2025, time.October), want: 3},
+		{name: "ends inside", start: NewMonth(2024, time.June), end: month(time.March, 2025), want: 3},
+		{name: "inside the period", start: NewMonth(2025, time.April), end: month(time.May, 2025), want: 2},
+		{name: "ended before", start: NewMonth(2024, time.January), end: month(time.December, 2024), want: 0},
+		{name: "starts after", start: NewMonth(2026, time.January), want: 0},
+		{name: "covers the period", start: NewMonth(2024, time.January), end: month(time.January, 2026), want: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Subscription{StartDate: tt.start, EndDate: tt.end}
+
+			if got := s.MonthsIn(period); got != tt.want {
+				t.Errorf("MonthsIn = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+
+func TestMonthJSON(t *testing.T) {
+	data, err := json.Marshal(NewMonth(2025, time.July))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"07-2025"` {
+		t.Errorf("Marshal = %s, want \"07-2025\"", data)
+	}
+
+	var m Month
+	if err := json.Unmarshal([]byte(`"02-2024"`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m != NewMonth(2024, time.February) {
+		t.Errorf("Unmarshal = %v, want 02-2024", m)
+	}
+
+	if err := json.Unmarshal([]byte(`"2024-02"`), &m); !errors.Is(err, ErrInvalidMonth) {
+		t.Errorf("Unmarshal of a malformed month error = %v, want ErrInvalidMonth", err)
+	}
+}
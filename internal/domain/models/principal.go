@@ -0,0 +1,27 @@
+package models
+
+const (
+	// RoleAdmin sees and changes every subscription.
+	RoleAdmin = "admin"
+	// RoleUser only sees and changes subscriptions whose user_id is its
+	// subject.
+	RoleUser = "user"
+)
+
+
+// Principal is who a request is authenticated as.
+type Principal struct {
+	Subject string
+	Role    string
+}
+
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+
+// Owns reports whether p may act on the subscriptions of userID.
+func (p Principal) Owns(userID string) bool {
+	return p.IsAdmin() || (p.Subject != "" && p.Subject == userID)
+}
@@ -1,10 +1,141 @@
 package models
 
+import "time"
 
+// Actions of the history, which are also the operations of a batch.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+
+// Subscription is billed Price every month from StartDate to EndDate, both
+// included. A nil EndDate means the subscription runs until cancelled.
+// Version starts at 1 and goes up with every change, so a write can be made
+// conditional on the version it was based on.
 type Subscription struct {
 	Id int64 `json:"id"`
 	ServiceName string  `json:"service_name"`
-	Price       int `json:"price"`
+	Price       Money   `json:"price"`
 	UserID      string  `json:"user_id"`
-	StartDate   string  `json:"start_date"`
+	StartDate   Month   `json:"start_date" swaggertype:"string" example:"07-2025"`
+	EndDate     *Month  `json:"end_date,omitempty" swaggertype:"string" example:"12-2025"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Version     int64      `json:"version"`
+}
+
+
+// ActiveIn reports whether s is billed in month.
+func (s Subscription) ActiveIn(month Month) bool {
+	return !month.Before(s.StartDate) && (s.EndDate == nil || !month.After(*s.EndDate))
+}
+
+
+// MonthsIn counts the months of period s is billed in.
+func (s Subscription) MonthsIn(period Period) int64 {
+	from, to := period.From, period.To
+
+	if s.StartDate.After(from) {
+		from = s.StartDate
+	}
+
+	if s.EndDate != nil && s.EndDate.Before(to) {
+		to = *s.EndDate
+	}
+
+	return from.MonthsUntil(to)
+}
+
+
+// Patch changes the fields of a subscription that are set. ClearEndDate makes
+// the subscription open-ended and takes precedence over EndDate.
+type Patch struct {
+	ServiceName  *string
+	Price        *Money
+	UserID       *string
+	StartDate    *Month
+	EndDate      *Month
+	ClearEndDate bool
+}
+
+
+// Apply changes the fields of s that p sets.
+func (p Patch) Apply(s *Subscription) {
+	if p.ServiceName != nil {
+		s.ServiceName = *p.ServiceName
+	}
+	if p.Price != nil {
+		s.Price = *p.Price
+	}
+	if p.UserID != nil {
+		s.UserID = *p.UserID
+	}
+	if p.StartDate != nil {
+		s.StartDate = *p.StartDate
+	}
+
+	switch {
+	case p.ClearEndDate:
+		s.EndDate = nil
+	case p.EndDate != nil:
+		endDate := *p.EndDate
+		s.EndDate = &endDate
+	}
+}
+
+
+// SubscriptionChange is one entry of a subscription's history. OldValue is
+// empty for create and restore, NewValue for delete.
+type SubscriptionChange struct {
+	Id             int64         `json:"id"`
+	SubscriptionId int64         `json:"subscription_id"`
+	Action         string        `json:"action"`
+	OldValue       *Subscription `json:"old_value,omitempty"`
+	NewValue       *Subscription `json:"new_value,omitempty"`
+	ChangedAt      time.Time     `json:"changed_at"`
+}
+
+
+// CostGroup is the cost of the subscriptions sharing the same values of the
+// fields a report is grouped by. Fields the report isn't grouped by are empty.
+// Groups are always split by currency, so Total is never a mix of currencies.
+// Count is the number of subscriptions in the group.
+type CostGroup struct {
+	ServiceName string `json:"service_name,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+	Month       string `json:"month,omitempty"`
+	Count       int64  `json:"count"`
+	Total       Money  `json:"total"`
+}
+
+
+// CostReport is the cost of subscriptions split into groups, along with the
+// total of all groups in each currency ordered by currency code.
+type CostReport struct {
+	Groups []CostGroup
+	Totals []Money
+}
+
+
+// SumResult is the cost of the subscriptions matching a sum request, with one
+// total per currency ordered by currency code. Subscriptions is only filled in
+// when a breakdown was asked for.
+type SumResult struct {
+	Totals        []Money
+	Count         int64
+	Subscriptions []SubscriptionCost
+}
+
+
+// SubscriptionCost is what one subscription contributes to a sum: its monthly
+// price times the number of months it overlaps the period.
+type SubscriptionCost struct {
+	Id          int64  `json:"id"`
+	ServiceName string `json:"service_name"`
+	UserID      string `json:"user_id"`
+	Price       Money  `json:"price"`
+	Months      int64  `json:"months"`
+	Cost        Money  `json:"cost"`
 }
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+)
+
+type principalKey struct{}
+
+
+// WithPrincipal returns a copy of ctx acting as principal. Subscriptions
+// scopes every call to the principal of its context: admins reach every
+// subscription, other principals only those whose user_id is their subject.
+func WithPrincipal(ctx context.Context, principal models.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+
+// PrincipalFrom returns the principal stored by WithPrincipal, or the zero
+// Principal, which reaches no subscription.
+func PrincipalFrom(ctx context.Context) models.Principal {
+	principal, _ := ctx.Value(principalKey{}).(models.Principal)
+	return principal
+}
+
+
+// authorizeSubscription lets the principal of ctx act on the subscription with
+// id if it owns it. Someone else's subscription is reported as not found, so
+// callers can't probe which ids exist.
+func (s *Subscriptions) authorizeSubscription(ctx context.Context, id int64) error {
+	principal := PrincipalFrom(ctx)
+	if principal.IsAdmin() {
+		return nil
+	}
+
+	owner, err := s.storage.Owner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !principal.Owns(owner) {
+		return fmt.Errorf("owned by another user: %w", storage.ErrSubscriptionNotFound)
+	}
+
+	return nil
+}
+
+
+// authorizeUserID checks that the principal of ctx may act on subscriptions of
+// userID.
+func authorizeUserID(ctx context.Context, userID string) error {
+	if !PrincipalFrom(ctx).Owns(userID) {
+		return fmt.Errorf("%w: user_id of another user", ErrForbidden)
+	}
+
+	return nil
+}
+
+
+// scopeUserID narrows a user_id filter to the principal of ctx: non-admins get
+// their own subject when they didn't set one and ErrForbidden when they set
+// someone else's.
+func scopeUserID(ctx context.Context, userID *string) error {
+	principal := PrincipalFrom(ctx)
+	if !principal.IsAdmin() && *userID == "" {
+		*userID = principal.Subject
+	}
+
+	return authorizeUserID(ctx, *userID)
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+)
+
+// MaxBatchSize is the most operations a single batch may hold.
+const MaxBatchSize = 1000
+
+
+// Batch runs operations in order, in one transaction. Operations are checked
+// before any of them runs: with atomic set an invalid operation aborts the
+// batch, otherwise it is only reported and the valid ones still run. The
+// results are in the order of operations; a batch that was rolled back
+// returns them along with storage.ErrBatchAborted. Operations on
+// subscriptions the principal of ctx doesn't own fail like invalid ones.
+func (s *Subscriptions) Batch(ctx context.Context, operations []models.BatchOperation, atomic bool) ([]storage.BatchResult, error) {
+	const op = "domain.service.Batch"
+
+	if len(operations) == 0 || len(operations) > MaxBatchSize {
+		return nil, fmt.Errorf("%s: %w: %d operations", op, ErrInvalidBatchSize, len(operations))
+	}
+
+	results := make([]storage.BatchResult, len(operations))
+	for i, operation := range operations {
+		results[i].Op = operation.Op
+	}
+
+	// valid maps the operations sent to storage to their index in operations.
+	var (
+		valid   []int
+		checked []models.BatchOperation
+	)
+
+	for i, operation := range operations {
+		err := checkBatchOperation(operation)
+		if err == nil {
+			err = s.authorizeBatchOperation(ctx, operation)
+		}
+
+		if err != nil {
+			results[i].Err = err
+
+			if atomic {
+				return results, fmt.Errorf("%s: %w", op, storage.ErrBatchAborted)
+			}
+			continue
+		}
+
+		valid = append(valid, i)
+		checked = append(checked, operation)
+	}
+
+	if len(checked) == 0 {
+		return results, nil
+	}
+
+	stored, err := s.storage.Batch(ctx, checked, atomic)
+	if err != nil && !errors.Is(err, storage.ErrBatchAborted) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for n, result := range stored {
+		results[valid[n]] = result
+	}
+
+	if err != nil {
+		return results, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return results, nil
+}
+
+
+// authorizeBatchOperation checks that the principal of ctx may run operation,
+// the way the single-subscription calls check it.
+func (s *Subscriptions) authorizeBatchOperation(ctx context.Context, operation models.BatchOperation) error {
+	if operation.Op != models.ActionCreate {
+		if err := s.authorizeSubscription(ctx, operation.ID); err != nil {
+			return err
+		}
+	}
+
+	if operation.Subscription != nil {
+		return authorizeUserID(ctx, operation.Subscription.UserID)
+	}
+
+	return nil
+}
+
+
+// checkBatchOperation reports whether operation is complete enough to run.
+func checkBatchOperation(operation models.BatchOperation) error {
+	switch operation.Op {
+	case models.ActionCreate:
+		if operation.Version != 0 || operation.Force {
+			return fmt.Errorf("%w: version and force are only for update and delete", ErrInvalidBatchOperation)
+		}
+	case models.ActionUpdate, models.ActionDelete:
+		if operation.ID == 0 {
+			return fmt.Errorf("%w: id is required", ErrInvalidBatchOperation)
+		}
+
+		if operation.Version != 0 && operation.Force {
+			return fmt.Errorf("%w: version and force exclude each other", ErrInvalidBatchOperation)
+		}
+
+		if operation.Version == 0 && !operation.Force {
+			return ErrVersionRequired
+		}
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidBatchOperation, operation.Op)
+	}
+
+	if operation.Op == models.ActionDelete {
+		return nil
+	}
+
+	if operation.Subscription == nil {
+		return fmt.Errorf("%w: subscription is required", ErrInvalidBatchOperation)
+	}
+
+	if problem := incomplete(*operation.Subscription); problem != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidBatchOperation, problem)
+	}
+
+	if end := operation.Subscription.EndDate; end != nil && end.Before(operation.Subscription.StartDate) {
+		return ErrEndDateBeforeStartDate
+	}
+
+	return nil
+}
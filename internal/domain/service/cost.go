@@ -0,0 +1,123 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/money"
+)
+
+// Sum returns the cost of the subscriptions matching filter in each currency
+// they are billed in. Every subscription costs its monthly price once for each
+// month it is billed in during filter.Period. With detail set the result also
+// holds what every subscription contributes, in id order.
+func (s *Subscriptions) Sum(ctx context.Context, filter models.CostFilter, detail bool) (models.SumResult, error) {
+	const op = "domain.service.Sum"
+
+	if err := scopeUserID(ctx, &filter.UserID); err != nil {
+		return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkCostFilter(filter); err != nil {
+		return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := s.storage.Sum(ctx, filter, detail)
+	if err != nil {
+		return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+
+// Report returns the cost of the subscriptions matching filter split into
+// groups by the groupBy fields, in that order of precedence, and then by
+// currency. Every subscription adds its monthly price to the group of each
+// month it is billed in during filter.Period.
+func (s *Subscriptions) Report(ctx context.Context, filter models.CostFilter, groupBy []string) (models.CostReport, error) {
+	const op = "domain.service.Report"
+
+	if err := scopeUserID(ctx, &filter.UserID); err != nil {
+		return models.CostReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkCostFilter(filter); err != nil {
+		return models.CostReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i, field := range groupBy {
+		switch field {
+		case models.GroupByServiceName, models.GroupByUserID, models.GroupByMonth:
+		default:
+			return models.CostReport{}, fmt.Errorf("%s: %w", op, ErrInvalidGroupBy)
+		}
+
+		if slices.Contains(groupBy[:i], field) {
+			return models.CostReport{}, fmt.Errorf("%s: %w", op, ErrInvalidGroupBy)
+		}
+	}
+
+	groups, err := s.storage.Report(ctx, filter, groupBy)
+	if err != nil {
+		return models.CostReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := models.CostReport{
+		Groups: groups,
+		Totals: []models.Money{},
+	}
+
+	for _, group := range groups {
+		report.Totals = addTotal(report.Totals, group.Total)
+	}
+
+	return report, nil
+}
+
+
+// Convert adds up totals in currency at the configured exchange rates. It
+// fails with a money.UnknownRateError when a currency has no rate.
+func (s *Subscriptions) Convert(totals []models.Money, currency string) (models.Money, error) {
+	return money.Convert(s.rates, totals, currency)
+}
+
+
+// checkCostFilter reports a period with a missing bound or ending before it
+// starts, and a user_id that isn't a UUID.
+func checkCostFilter(filter models.CostFilter) error {
+	period := filter.Period
+
+	switch {
+	case filter.UserID != "" && !uuidPattern.MatchString(filter.UserID):
+		return fmt.Errorf("%w: user_id must be a UUID", ErrInvalidFilter)
+	case period.From.IsZero():
+		return ErrInvalidStartDateFormat
+	case period.To.IsZero():
+		return ErrInvalidEndDateFormat
+	case period.To.Before(period.From):
+		return ErrEndDateBeforeStartDate
+	}
+
+	return nil
+}
+
+
+// addTotal adds amount to the total of its currency in totals, which stay
+// ordered by currency code.
+func addTotal(totals []models.Money, amount models.Money) []models.Money {
+	i, found := slices.BinarySearchFunc(totals, amount.Currency, func(total models.Money, currency string) int {
+		return cmp.Compare(total.Currency, currency)
+	})
+
+	if !found {
+		totals = slices.Insert(totals, i, models.Money{Currency: amount.Currency})
+	}
+
+	totals[i].Amount += amount.Amount
+
+	return totals
+}
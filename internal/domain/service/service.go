@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/money"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+)
+
+const (
+	// DefaultListLimit is the page size of List when the filter sets none.
+	DefaultListLimit = 100
+	// MaxListLimit is the largest page List returns.
+	MaxListLimit = 1000
+
+	maxServiceNameLength = 100
+)
+
+// uuidPattern matches the lower-case UUIDs user ids are written as.
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+var (
+	ErrInvalidSubscription = errors.New("invalid subscription")
+	ErrInvalidStartDateFormat = errors.New("invalid start_date format")
+	ErrInvalidEndDateFormat = errors.New("invalid end_date format")
+	ErrEndDateBeforeStartDate = errors.New("end_date is before start_date")
+	ErrEmptyPatch = errors.New("no fields to update")
+	ErrInvalidGroupBy = errors.New("invalid group_by field")
+	ErrInvalidSortField = errors.New("invalid sort field")
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrInvalidBatchOperation = errors.New("invalid batch operation")
+	ErrInvalidBatchSize = errors.New("invalid batch size")
+	// ErrVersionRequired is returned for a batch update or delete with
+	// neither a version nor force.
+	ErrVersionRequired = errors.New("version is required")
+	// ErrForbidden is returned when the principal of the context names
+	// another user's user_id.
+	ErrForbidden = errors.New("forbidden")
+)
+
+
+// Storage persists subscriptions. Implementations report a missing or deleted
+// subscription with storage.ErrSubscriptionNotFound and a duplicate of
+// (user_id, service_name, start_date) with storage.ErrSubscriptionExists. They
+// don't validate what they are given, Subscriptions does that before calling
+// them. Subscriptions are created at version 1 and every write raises the
+// version by one; writes given a non-zero version fail with
+// storage.ErrVersionMismatch unless it is the stored one.
+type Storage interface {
+	Create(ctx context.Context, subscription models.Subscription) (int64, error)
+	Read(ctx context.Context, id int64) (models.Subscription, error)
+	// Owner returns the user_id of the subscription, whether it is deleted or
+	// not.
+	Owner(ctx context.Context, id int64) (string, error)
+	List(ctx context.Context, filter models.ListFilter) ([]models.Subscription, int64, error)
+	// Export calls fn for every subscription that isn't deleted, in id order.
+	Export(ctx context.Context, fn func(models.Subscription) error) error
+	// Update replaces the subscription with the id and version of
+	// subscription and returns it as stored.
+	Update(ctx context.Context, subscription models.Subscription) (models.Subscription, error)
+	// Patch changes only the fields patch sets of the subscription with id
+	// and returns it as stored. check is given the patched subscription before
+	// it is written; an error from it leaves the subscription as it was and is
+	// returned as is.
+	Patch(ctx context.Context, id int64, version int64, patch models.Patch, check func(models.Subscription) error) (models.Subscription, error)
+	Delete(ctx context.Context, id int64, version int64) error
+	// Restore brings back a deleted subscription. It fails with
+	// storage.ErrSubscriptionExists if an identical subscription was created
+	// since.
+	Restore(ctx context.Context, id int64) (models.Subscription, error)
+	History(ctx context.Context, id int64) ([]models.SubscriptionChange, error)
+	// Batch runs the operations in one transaction. With atomic set the first
+	// failing operation undoes the whole batch and Batch returns
+	// storage.ErrBatchAborted, otherwise a failure only undoes that
+	// operation.
+	Batch(ctx context.Context, operations []models.BatchOperation, atomic bool) ([]storage.BatchResult, error)
+	// Sum returns the cost of the subscriptions that aren't deleted, match
+	// filter and are billed in filter.Period, with one total per currency
+	// ordered by currency code. Every subscription costs its monthly price
+	// once for each month of the period it is billed in. With detail set the
+	// result also holds what every subscription contributes, in id order.
+	Sum(ctx context.Context, filter models.CostFilter, detail bool) (models.SumResult, error)
+	// Report returns the cost of the subscriptions Sum adds up, grouped by the
+	// distinct GroupBy fields of groupBy and then by currency, and ordered the
+	// same way.
+	Report(ctx context.Context, filter models.CostFilter, groupBy []string) ([]models.CostGroup, error)
+}
+
+
+// Subscriptions is what the front ends work with. It validates what it is
+// given, so storage only ever sees complete subscriptions, and computes costs.
+// Calls only reach the subscriptions of the principal of their context, see
+// WithPrincipal.
+type Subscriptions struct {
+	storage Storage
+	rates   money.Rates
+}
+
+
+// New returns the Subscriptions kept in storage. rates converts cost totals
+// to a single currency.
+func New(storage Storage, rates money.Rates) *Subscriptions {
+	return &Subscriptions{
+		storage: storage,
+		rates:   rates,
+	}
+}
+
+
+// Create stores subscription and returns it with the id it was given.
+func (s *Subscriptions) Create(ctx context.Context, subscription models.Subscription) (models.Subscription, error) {
+	const op = "domain.service.Create"
+
+	if err := authorizeUserID(ctx, subscription.UserID); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := validate(subscription); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := s.storage.Create(ctx, subscription)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscription.Id = id
+	subscription.Version = 1
+
+	return subscription, nil
+}
+
+
+func (s *Subscriptions) Read(ctx context.Context, id int64) (models.Subscription, error) {
+	const op = "domain.service.Read"
+
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscription, err := s.storage.Read(ctx, id)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscription, nil
+}
+
+
+// List returns one page of the subscriptions matching filter and where the
+// next one starts, and their total number when filter.CountTotal is set. A
+// filter without a limit gets DefaultListLimit, a larger limit than
+// MaxListLimit is lowered to it.
+func (s *Subscriptions) List(ctx context.Context, filter models.ListFilter) (models.ListPage, error) {
+	const op = "domain.service.List"
+
+	if err := scopeUserID(ctx, &filter.UserID); err != nil {
+		return models.ListPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkListFilter(filter); err != nil {
+		return models.ListPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = models.SortByID
+	case models.SortByID, models.SortByPrice, models.SortByStartDate:
+	default:
+		return models.ListPage{}, fmt.Errorf("%s: %w", op, ErrInvalidSortField)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultListLimit
+	}
+	limit := min(filter.Limit, MaxListLimit)
+
+	filter.Offset = max(filter.Offset, 0)
+
+	// One subscription more than asked for tells whether there is a next page.
+	filter.Limit = limit + 1
+
+	subscriptions, total, err := s.storage.List(ctx, filter)
+	if err != nil {
+		return models.ListPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.ListPage{Subscriptions: subscriptions}
+
+	if len(subscriptions) > limit {
+		page.Subscriptions = subscriptions[:limit]
+
+		next := models.CursorOf(subscriptions[limit-1])
+		page.Next = &next
+	}
+
+	if filter.CountTotal {
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+
+// Export calls fn for every subscription that isn't deleted, in id order.
+// Non-admins only get their own subscriptions.
+func (s *Subscriptions) Export(ctx context.Context, fn func(models.Subscription) error) error {
+	const op = "domain.service.Export"
+
+	var userID string
+	if err := scopeUserID(ctx, &userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	export := fn
+	if userID != "" {
+		export = func(subscription models.Subscription) error {
+			if subscription.UserID != userID {
+				return nil
+			}
+
+			return fn(subscription)
+		}
+	}
+
+	if err := s.storage.Export(ctx, export); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+
+// Update replaces every field of the subscription with id and returns it as
+// stored. It fails with storage.ErrVersionMismatch when version isn't the
+// stored version; version 0 replaces whatever is stored.
+func (s *Subscriptions) Update(ctx context.Context, id int64, version int64, subscription models.Subscription) (models.Subscription, error) {
+	const op = "domain.service.Update"
+
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := authorizeUserID(ctx, subscription.UserID); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscription.Id = id
+	subscription.Version = version
+
+	if err := validate(subscription); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscription, err := s.storage.Update(ctx, subscription)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscription, nil
+}
+
+
+// Patch applies patch to the subscription with id and returns it as stored.
+// The patched subscription must be as valid as a new one. version works as for
+// Update.
+func (s *Subscriptions) Patch(ctx context.Context, id int64, version int64, patch models.Patch) (models.Subscription, error) {
+	const op = "domain.service.Patch"
+
+	if patch == (models.Patch{}) {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, ErrEmptyPatch)
+	}
+
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if patch.UserID != nil {
+		if err := authorizeUserID(ctx, *patch.UserID); err != nil {
+			return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	subscription, err := s.storage.Patch(ctx, id, version, patch, validate)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscription, nil
+}
+
+
+// Delete soft-deletes the subscription, it can be brought back with Restore.
+// version works as for Update.
+func (s *Subscriptions) Delete(ctx context.Context, id int64, version int64) error {
+	const op = "domain.service.Delete"
+
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.storage.Delete(ctx, id, version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+
+// Restore brings back a deleted subscription and returns it.
+func (s *Subscriptions) Restore(ctx context.Context, id int64) (models.Subscription, error) {
+	const op = "domain.service.Restore"
+
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscription, err := s.storage.Restore(ctx, id)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscription, nil
+}
+
+
+// History returns every recorded change of the subscription, oldest first.
+func (s *Subscriptions) History(ctx context.Context, id int64) ([]models.SubscriptionChange, error) {
+	const op = "domain.service.History"
+
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	changes, err := s.storage.History(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changes, nil
+}
+
+
+// validate checks that subscription is complete and that its end date, when
+// set, isn't before its start date.
+func validate(subscription models.Subscription) error {
+	if problem := incomplete(subscription); problem != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidSubscription, problem)
+	}
+
+	if subscription.EndDate != nil && subscription.EndDate.Before(subscription.StartDate) {
+		return ErrEndDateBeforeStartDate
+	}
+
+	return nil
+}
+
+
+// checkListFilter reports a filter field List can't filter by.
+func checkListFilter(filter models.ListFilter) error {
+	switch {
+	case filter.UserID != "" && !uuidPattern.MatchString(filter.UserID):
+		return fmt.Errorf("%w: user_id must be a UUID", ErrInvalidFilter)
+	case filter.Currency != "" && !models.IsCurrencyCode(filter.Currency):
+		return fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidFilter)
+	case filter.MinPrice != nil && *filter.MinPrice < 0, filter.MaxPrice != nil && *filter.MaxPrice < 0:
+		return fmt.Errorf("%w: prices must not be negative", ErrInvalidFilter)
+	}
+
+	return nil
+}
+
+
+// incomplete describes the first missing or malformed field of subscription,
+// or returns "" when there is none.
+func incomplete(subscription models.Subscription) string {
+	switch {
+	case subscription.ServiceName == "":
+		return "service_name is required"
+	case utf8.RuneCountInString(subscription.ServiceName) > maxServiceNameLength:
+		return fmt.Sprintf("service_name must be at most %d characters", maxServiceNameLength)
+	case subscription.Price.Amount < 0:
+		return "price.amount must not be negative"
+	case !models.IsCurrencyCode(subscription.Price.Currency):
+		return "price.currency must be an ISO 4217 code"
+	case subscription.UserID == "":
+		return "user_id is required"
+	case !uuidPattern.MatchString(subscription.UserID):
+		return "user_id must be a UUID"
+	case subscription.StartDate.IsZero():
+		return "start_date is required"
+	}
+
+	return ""
+}
@@ -0,0 +1,21 @@
+package grpcserver
+
+import (
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// requireVersion rejects an update or delete without the version the caller
+// read, as the HTTP API does without If-Match. Version 0 overwrites any
+// version.
+func requireVersion(log *slog.Logger, version *int64) error {
+	if version != nil && *version >= 0 {
+		return nil
+	}
+
+	log.Warn("version missing or invalid")
+
+	return status.Error(codes.FailedPrecondition, "version of the subscription is required, 0 to overwrite any version")
+}
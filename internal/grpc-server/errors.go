@@ -0,0 +1,69 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/apierror"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the ErrorInfo details of errors.
+const errorDomain = "data-aggregation"
+
+// statusCodes maps the HTTP statuses apierror gives errors to gRPC codes, so an
+// error fails a call the way it fails the matching HTTP request.
+var statusCodes = map[int]codes.Code{
+	http.StatusBadRequest:           codes.InvalidArgument,
+	http.StatusUnauthorized:         codes.Unauthenticated,
+	http.StatusForbidden:            codes.PermissionDenied,
+	http.StatusNotFound:             codes.NotFound,
+	http.StatusConflict:             codes.AlreadyExists,
+	http.StatusPreconditionFailed:   codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusUnprocessableEntity:  codes.InvalidArgument,
+	http.StatusPreconditionRequired: codes.FailedPrecondition,
+	http.StatusServiceUnavailable:   codes.Unavailable,
+	http.StatusGatewayTimeout:       codes.DeadlineExceeded,
+}
+
+
+// statusError is the single place the gRPC API turns an error into a status,
+// with the code and message the HTTP API would answer with. The apierror code
+// is attached as the reason of an ErrorInfo detail. msg describes what failed
+// for the log.
+func statusError(log *slog.Logger, msg string, err error) error {
+	if errors.Is(err, context.Canceled) {
+		log.Warn("request cancelled by client", sl.Err(err))
+
+		return status.Error(codes.Canceled, "request cancelled")
+	}
+
+	apiErr := apierror.Lookup(err)
+
+	code, ok := statusCodes[apiErr.Status]
+	if !ok {
+		code = codes.Internal
+	}
+
+	if code == codes.Internal {
+		log.Error(msg, sl.Err(err))
+	} else {
+		log.Warn(msg, sl.Err(err))
+	}
+
+	st, detailErr := status.New(code, apiErr.Message).WithDetails(&errdetails.ErrorInfo{
+		Reason: apiErr.Code,
+		Domain: errorDomain,
+	})
+	if detailErr != nil {
+		return status.Error(code, apiErr.Message)
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,216 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/authn"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys carrying the same values as the HTTP headers of the same name.
+const (
+	metadataAPIKey        = "x-api-key"
+	metadataAuthorization = "authorization"
+	metadataRequestID     = "x-request-id"
+)
+
+// healthService is left out of authentication, orchestrators have no
+// credentials.
+const healthService = "/grpc.health.v1.Health/"
+
+
+// unaryAccessLog gives every call a request ID, sent back in the response
+// headers, and logs one line per call once it is handled: at error level for
+// server errors, warn for client errors and info otherwise.
+func unaryAccessLog(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		ctx = withRequestID(ctx)
+
+		resp, err := handler(ctx, req)
+
+		logCall(ctx, log, info.FullMethod, err, start)
+
+		return resp, err
+	}
+}
+
+
+func streamAccessLog(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		ctx := withRequestID(stream.Context())
+
+		err := handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+
+		logCall(ctx, log, info.FullMethod, err, start)
+
+		return err
+	}
+}
+
+
+// unaryRecovery turns a panic in a handler into an Internal error, so one bad
+// call doesn't take the server down.
+func unaryRecovery(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = recovery(ctx, log, info.FullMethod, recovered)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+
+func streamRecovery(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = recovery(stream.Context(), log, info.FullMethod, recovered)
+			}
+		}()
+
+		return handler(srv, stream)
+	}
+}
+
+
+// recovery logs a recovered panic with its stack and returns the error
+// answering the call.
+func recovery(ctx context.Context, log *slog.Logger, method string, recovered any) error {
+	log.Error("handler panicked",
+		requestid.Attr(ctx),
+		slog.Any("panic", recovered),
+		slog.String("method", method),
+		slog.String("stack", string(debug.Stack())),
+	)
+
+	return status.Error(codes.Internal, "internal server error")
+}
+
+
+// unaryAuth rejects calls without valid credentials with Unauthenticated and
+// makes the others act as their principal, see service.WithPrincipal.
+func unaryAuth(log *slog.Logger, authenticator *authn.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate(ctx, log, authenticator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+
+func streamAuth(log *slog.Logger, authenticator *authn.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(srv, stream)
+		}
+
+		ctx, err := authenticate(stream.Context(), log, authenticator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+
+func authenticate(ctx context.Context, log *slog.Logger, authenticator *authn.Authenticator, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	principal, err := authenticator.Authenticate(first(md, metadataAPIKey), first(md, metadataAuthorization))
+	if err != nil {
+		log.Warn("unauthenticated request",
+			requestid.Attr(ctx),
+			slog.String("method", method),
+			sl.Err(err),
+		)
+
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	return service.WithPrincipal(ctx, principal), nil
+}
+
+
+// withRequestID stores the request ID of the call, or a new one, in ctx and
+// sends it back in the response headers.
+func withRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	ctx, id := requestid.NewContext(ctx, first(md, metadataRequestID))
+
+	// Fails only when headers were already sent, which they can't be yet.
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, id))
+
+	return ctx
+}
+
+
+func logCall(ctx context.Context, log *slog.Logger, method string, err error, start time.Time) {
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.Unimplemented, codes.DeadlineExceeded:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+
+	if code == codes.OK && strings.HasPrefix(method, healthService) {
+		level = slog.LevelDebug
+	}
+
+	log.LogAttrs(ctx, level, "request handled",
+		requestid.Attr(ctx),
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+
+// serverStream replaces the context of a stream, which grpc.ServerStream
+// doesn't allow otherwise.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,422 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	subscriptionv1 "github.com/BahadirAhmedov/data-aggregation/api/subscription/v1"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/authn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Subscriptioner is the part of service.Subscriptions the gRPC API uses, the
+// same service the HTTP handlers call, so both validate alike. Requests are
+// only converted to models here, the service checks them.
+type Subscriptioner interface {
+	Create(ctx context.Context, subscription models.Subscription) (models.Subscription, error)
+	Read(ctx context.Context, id int64) (models.Subscription, error)
+	Update(ctx context.Context, id int64, version int64, subscription models.Subscription) (models.Subscription, error)
+	Delete(ctx context.Context, id int64, version int64) error
+	List(ctx context.Context, filter models.ListFilter) (models.ListPage, error)
+	Sum(ctx context.Context, filter models.CostFilter, detail bool) (models.SumResult, error)
+	Convert(totals []models.Money, currency string) (models.Money, error)
+}
+
+
+// Server implements subscriptionv1.SubscriptionServiceServer.
+type Server struct {
+	subscriptionv1.UnimplementedSubscriptionServiceServer
+
+	log      *slog.Logger
+	provider Subscriptioner
+}
+
+
+// New returns a gRPC server with the subscription service, the standard health
+// service and reflection registered. Every call but health checks must
+// authenticate with authenticator, and a call that panics fails with Internal.
+// Shut the returned health server down when draining, so health checks fail
+// before the server stops.
+func New(log *slog.Logger, authenticator *authn.Authenticator, provider Subscriptioner) (*grpc.Server, *health.Server) {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryAccessLog(log), unaryRecovery(log), unaryAuth(log, authenticator)),
+		grpc.ChainStreamInterceptor(streamAccessLog(log), streamRecovery(log), streamAuth(log, authenticator)),
+	)
+
+	subscriptionv1.RegisterSubscriptionServiceServer(srv, &Server{
+		log:      log,
+		provider: provider,
+	})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	reflection.Register(srv)
+
+	return srv, healthServer
+}
+
+
+func (s *Server) CreateSubscription(ctx context.Context, request *subscriptionv1.CreateSubscriptionRequest) (*subscriptionv1.Subscription, error) {
+	const op = "grpc-server.CreateSubscription"
+
+	log := s.log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx),
+	)
+
+	subscription, err := fromSubscription(request)
+	if err != nil {
+		return nil, statusError(log, "invalid subscription dates", err)
+	}
+
+	subscription, err = s.provider.Create(ctx, subscription)
+	if err != nil {
+		return nil, statusError(log, "failed to create subscription", err)
+	}
+
+	log.Info("subscription created", slog.Int64("id", subscription.Id))
+
+	return toSubscription(subscription), nil
+}
+
+
+func (s *Server) GetSubscription(ctx context.Context, request *subscriptionv1.GetSubscriptionRequest) (*subscriptionv1.Subscription, error) {
+	const op = "grpc-server.GetSubscription"
+
+	log := s.log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx),
+	)
+
+	subscription, err := s.provider.Read(ctx, request.GetId())
+	if err != nil {
+		return nil, statusError(log, "failed to read subscription", err)
+	}
+
+	return toSubscription(subscription), nil
+}
+
+
+func (s *Server) UpdateSubscription(ctx context.Context, request *subscriptionv1.UpdateSubscriptionRequest) (*subscriptionv1.Subscription, error) {
+	const op = "grpc-server.UpdateSubscription"
+
+	log := s.log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx),
+		slog.Int64("id", request.GetId()),
+	)
+
+	if err := requireVersion(log, request.Version); err != nil {
+		return nil, err
+	}
+
+	subscription, err := fromSubscription(request)
+	if err != nil {
+		return nil, statusError(log, "invalid subscription dates", err)
+	}
+
+	subscription, err = s.provider.Update(ctx, request.GetId(), request.GetVersion(), subscription)
+	if err != nil {
+		return nil, statusError(log, "failed to update subscription", err)
+	}
+
+	log.Info("subscription updated")
+
+	return toSubscription(subscription), nil
+}
+
+
+func (s *Server) DeleteSubscription(ctx context.Context, request *subscriptionv1.DeleteSubscriptionRequest) (*subscriptionv1.DeleteSubscriptionResponse, error) {
+	const op = "grpc-server.DeleteSubscription"
+
+	log := s.log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx),
+		slog.Int64("id", request.GetId()),
+	)
+
+	if err := requireVersion(log, request.Version); err != nil {
+		return nil, err
+	}
+
+	if err := s.provider.Delete(ctx, request.GetId(), request.GetVersion()); err != nil {
+		return nil, statusError(log, "failed to delete subscription", err)
+	}
+
+	log.Info("subscription deleted")
+
+	return &subscriptionv1.DeleteSubscriptionResponse{}, nil
+}
+
+
+func (s *Server) ListSubscriptions(ctx context.Context, request *subscriptionv1.ListSubscriptionsRequest) (*subscriptionv1.ListSubscriptionsResponse, error) {
+	const op = "grpc-server.ListSubscriptions"
+
+	log := s.log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx),
+	)
+
+	filter, err := listFilter(log, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response always carries the total.
+	filter.CountTotal = true
+
+	page, err := s.provider.List(ctx, filter)
+	if err != nil {
+		return nil, statusError(log, "failed to list subscriptions", err)
+	}
+
+	resp := &subscriptionv1.ListSubscriptionsResponse{
+		Subscriptions: make([]*subscriptionv1.Subscription, 0, len(page.Subscriptions)),
+		Total:         *page.Total,
+	}
+
+	for _, subscription := range page.Subscriptions {
+		resp.Subscriptions = append(resp.Subscriptions, toSubscription(subscription))
+	}
+
+	return resp, nil
+}
+
+
+// StreamSubscriptions pages through the matching subscriptions with the
+// largest page List allows, each starting after the last one, so
+// subscriptions created or deleted while streaming don't shift the pages.
+func (s *Server) StreamSubscriptions(request *subscriptionv1.ListSubscriptionsRequest, stream grpc.ServerStreamingServer[subscriptionv1.Subscription]) error {
+	const op = "grpc-server.StreamSubscriptions"
+
+	ctx := stream.Context()
+
+	log := s.log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx),
+	)
+
+	filter, err := listFilter(log, request)
+	if err != nil {
+		return err
+	}
+
+	filter.Limit = service.MaxListLimit
+	filter.Offset = 0
+
+	for {
+		page, err := s.provider.List(ctx, filter)
+		if err != nil {
+			return statusError(log, "failed to list subscriptions", err)
+		}
+
+		for _, subscription := range page.Subscriptions {
+			if err := stream.Send(toSubscription(subscription)); err != nil {
+				return statusError(log, "failed to send subscription", err)
+			}
+		}
+
+		if page.Next == nil {
+			return nil
+		}
+
+		filter.After = page.Next
+	}
+}
+
+
+func (s *Server) SumSubscriptions(ctx context.Context, request *subscriptionv1.SumSubscriptionsRequest) (*subscriptionv1.SumSubscriptionsResponse, error) {
+	const op = "grpc-server.SumSubscriptions"
+
+	log := s.log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx),
+	)
+
+	period, err := parsePeriod(request.GetStartDate(), request.GetEndDate())
+	if err != nil {
+		return nil, statusError(log, "invalid period", err)
+	}
+
+	filter := models.CostFilter{
+		Period:      period,
+		UserID:      request.GetUserId(),
+		ServiceName: request.GetServiceName(),
+	}
+
+	result, err := s.provider.Sum(ctx, filter, request.GetDetail())
+	if err != nil {
+		return nil, statusError(log, "failed to calculate sum", err)
+	}
+
+	resp := &subscriptionv1.SumSubscriptionsResponse{
+		Totals: toMoneys(result.Totals),
+		Count:  result.Count,
+	}
+
+	for _, cost := range result.Subscriptions {
+		resp.Subscriptions = append(resp.Subscriptions, &subscriptionv1.SubscriptionCost{
+			Id:          cost.Id,
+			ServiceName: cost.ServiceName,
+			UserId:      cost.UserID,
+			Price:       toMoney(cost.Price),
+			Months:      cost.Months,
+			Cost:        toMoney(cost.Cost),
+		})
+	}
+
+	if currency := request.GetConvertTo(); currency != "" {
+		converted, err := s.provider.Convert(result.Totals, currency)
+		if err != nil {
+			return nil, statusError(log, "failed to convert totals", err)
+		}
+
+		resp.Converted = toMoney(converted)
+	}
+
+	return resp, nil
+}
+
+
+// listFilter is the filter of request.
+func listFilter(log *slog.Logger, request *subscriptionv1.ListSubscriptionsRequest) (models.ListFilter, error) {
+	filter := models.ListFilter{
+		UserID:         request.GetUserId(),
+		ServiceName:    request.GetServiceName(),
+		Currency:       request.GetCurrency(),
+		MinPrice:       request.MinPrice,
+		MaxPrice:       request.MaxPrice,
+		IncludeDeleted: request.GetIncludeDeleted(),
+		SortBy:         strings.TrimPrefix(request.GetSort(), "-"),
+		Desc:           strings.HasPrefix(request.GetSort(), "-"),
+		Limit:          int(request.GetLimit()),
+		Offset:         int(request.GetOffset()),
+	}
+
+	var err error
+
+	if filter.StartFrom, err = parseOptionalMonth(request.GetStartDateFrom()); err != nil {
+		return models.ListFilter{}, statusError(log, "invalid filter", service.ErrInvalidStartDateFormat)
+	}
+
+	if filter.StartTo, err = parseOptionalMonth(request.GetStartDateTo()); err != nil {
+		return models.ListFilter{}, statusError(log, "invalid filter", service.ErrInvalidStartDateFormat)
+	}
+
+	return filter, nil
+}
+
+
+// subscriptionRequest is what creates and updates carry of a subscription.
+type subscriptionRequest interface {
+	GetServiceName() string
+	GetPrice() *subscriptionv1.Money
+	GetUserId() string
+	GetStartDate() string
+	GetEndDate() string
+}
+
+
+// fromSubscription is the subscription of request. Only the dates are checked,
+// for being MM-YYYY; a missing start_date is left to the service to reject.
+func fromSubscription(request subscriptionRequest) (models.Subscription, error) {
+	subscription := models.Subscription{
+		ServiceName: request.GetServiceName(),
+		Price:       fromMoney(request.GetPrice()),
+		UserID:      request.GetUserId(),
+	}
+
+	if startDate := request.GetStartDate(); startDate != "" {
+		month, err := models.ParseMonth(startDate)
+		if err != nil {
+			return models.Subscription{}, service.ErrInvalidStartDateFormat
+		}
+		subscription.StartDate = month
+	}
+
+	endDate, err := parseOptionalMonth(request.GetEndDate())
+	if err != nil {
+		return models.Subscription{}, service.ErrInvalidEndDateFormat
+	}
+	subscription.EndDate = endDate
+
+	return subscription, nil
+}
+
+
+func parsePeriod(startDate string, endDate string) (models.Period, error) {
+	from, err := models.ParseMonth(startDate)
+	if err != nil {
+		return models.Period{}, service.ErrInvalidStartDateFormat
+	}
+
+	to, err := models.ParseMonth(endDate)
+	if err != nil {
+		return models.Period{}, service.ErrInvalidEndDateFormat
+	}
+
+	return models.Period{From: from, To: to}, nil
+}
+
+
+// parseOptionalMonth parses an MM-YYYY month that may be left empty, which
+// gives nil.
+func parseOptionalMonth(s string) (*models.Month, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	month, err := models.ParseMonth(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &month, nil
+}
+
+
+func toSubscription(subscription models.Subscription) *subscriptionv1.Subscription {
+	resp := &subscriptionv1.Subscription{
+		Id:          subscription.Id,
+		ServiceName: subscription.ServiceName,
+		Price:       toMoney(subscription.Price),
+		UserId:      subscription.UserID,
+		StartDate:   subscription.StartDate.String(),
+		Version:     subscription.Version,
+	}
+
+	if subscription.EndDate != nil {
+		resp.EndDate = subscription.EndDate.String()
+	}
+
+	return resp
+}
+
+
+func toMoney(money models.Money) *subscriptionv1.Money {
+	return &subscriptionv1.Money{Amount: money.Amount, Currency: money.Currency}
+}
+
+
+func toMoneys(moneys []models.Money) []*subscriptionv1.Money {
+	resp := make([]*subscriptionv1.Money, 0, len(moneys))
+	for _, money := range moneys {
+		resp = append(resp, toMoney(money))
+	}
+	return resp
+}
+
+
+// fromMoney treats a missing price as the zero Money, which validation rejects
+// for its missing currency.
+func fromMoney(money *subscriptionv1.Money) models.Money {
+	return models.Money{Amount: money.GetAmount(), Currency: money.GetCurrency()}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursor is what next_cursor holds: the position the next page starts after
+// and the sort it is a position in, so a cursor isn't reused with another one.
+type cursor struct {
+	Sort      string       `json:"s"`
+	Price     int64        `json:"p"`
+	StartDate models.Month `json:"d"`
+	ID        int64        `json:"i"`
+}
+
+
+// encodeCursor turns the position of the next page into an opaque cursor, so
+// clients don't depend on how pages are addressed.
+func encodeCursor(sort string, position models.ListCursor) string {
+	raw, _ := json.Marshal(cursor{
+		Sort:      sort,
+		Price:     position.Price,
+		StartDate: position.StartDate,
+		ID:        position.ID,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+
+// decodeCursor returns the position encoded in s, which must have been issued
+// for sort.
+func decodeCursor(s string, sort string) (models.ListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.ListCursor{}, errInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sort || c.ID <= 0 {
+		return models.ListCursor{}, errInvalidCursor
+	}
+
+	return models.ListCursor{Price: c.Price, StartDate: c.StartDate, ID: c.ID}, nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/apierror"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// respondError is the single place handlers turn an error into a response,
+// with the status apierror gives it. msg describes what failed for the log. A
+// request cancelled by the client is only logged, as there is nobody left to
+// answer.
+func respondError(ctx *gin.Context, log *slog.Logger, msg string, err error) {
+	if errors.Is(err, context.Canceled) {
+		log.Warn("request cancelled by client", sl.Err(err))
+
+		ctx.Abort()
+
+		return
+	}
+
+	apiErr := apierror.Lookup(err)
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Error(msg, sl.Err(err))
+	} else {
+		log.Warn(msg, sl.Err(err))
+	}
+
+	httputil.Abort(ctx, apiErr.Status, httputil.Error(apiErr.Code, apiErr.Message))
+}
+
+
+// respondBindError reports a request that failed binding: 400 when it
+// couldn't be read, 422 with the offending fields when it broke validation.
+func respondBindError(ctx *gin.Context, log *slog.Logger, err error) {
+	log.Warn("invalid request", sl.Err(err))
+
+	status := http.StatusBadRequest
+	if validation.Failed(err) {
+		status = http.StatusUnprocessableEntity
+	}
+
+	resp := httputil.ValidationError(validation.Errors(err))
+	if status == http.StatusBadRequest {
+		resp.Code = httputil.CodeInvalidRequest
+		resp.Error = "invalid request"
+	}
+
+	httputil.Abort(ctx, status, resp)
+}
+
+
+// respondInvalidID reports an :id path parameter that isn't a number.
+func respondInvalidID(ctx *gin.Context, log *slog.Logger, err error) {
+	log.Warn("invalid subscription id", sl.Err(err))
+
+	httputil.Abort(ctx, http.StatusBadRequest, httputil.Error(httputil.CodeInvalidRequest, "could not parse subscription id"))
+}
+
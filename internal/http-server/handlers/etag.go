@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/apierror"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// setETag tags the response with the version of the subscription it carries.
+// The ETag is strong, a different version is a different subscription.
+func setETag(ctx *gin.Context, version int64) {
+	ctx.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+
+// ifMatch returns the version a write is conditional on, taken from the ETag
+// in If-Match; "*" writes whatever version is stored and gives 0. A write
+// without If-Match is answered with 428, one with an ETag this API didn't
+// issue with 412, as it can't match. It returns false when it answered.
+func ifMatch(ctx *gin.Context, log *slog.Logger) (int64, bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+
+	if header == "" {
+		log.Warn("write without If-Match")
+
+		httputil.Abort(ctx, http.StatusPreconditionRequired, httputil.Error(httputil.CodePreconditionRequired, "If-Match header with the subscription's ETag is required"))
+
+		return 0, false
+	}
+
+	if header == "*" {
+		return 0, true
+	}
+
+	// Weak ETags never match in If-Match, and this API only issues strong ones.
+	if len(header) > 2 && header[0] == '"' && header[len(header)-1] == '"' {
+		version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+		if err == nil && version > 0 {
+			return version, true
+		}
+	}
+
+	log.Warn("unknown ETag in If-Match", slog.String("if_match", header))
+
+	apiErr := apierror.Lookup(storage.ErrVersionMismatch)
+	httputil.Abort(ctx, apiErr.Status, httputil.Error(apiErr.Code, apiErr.Message))
+
+	return 0, false
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"strconv"
 
@@ -8,12 +9,15 @@ import (
 	"net/http"
 
 	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
 	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
 	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
 	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/requests"
 	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/responses"
 	_ "github.com/BahadirAhmedov/data-aggregation/cmd/data-aggregation/docs"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/apierror"
 	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
 
 
 	"github.com/gin-gonic/gin"
@@ -23,13 +27,22 @@ type Subscription struct{
 }
 
 
+// Subscriptioner is the part of service.Subscriptions the handlers use. It
+// takes domain values, handlers convert requests to them.
 type Subscriptioner interface{
-	Create(requests.CreateSubscriptionRequest) (int64, error)
-	Read(Id int64) (models.Subscription, error)
-	Update(req requests.UpdateSubscriptionRequest, Id int64) (int64, error)
-	Delete(Id int64) (int64, error)
-	List() ([]models.Subscription, error)
-	Sum(req requests.SumSubscriptionRequest) (int64, error)
+	Create(ctx context.Context, subscription models.Subscription) (models.Subscription, error)
+	Read(ctx context.Context, Id int64) (models.Subscription, error)
+	Update(ctx context.Context, Id int64, version int64, subscription models.Subscription) (models.Subscription, error)
+	Patch(ctx context.Context, Id int64, version int64, patch models.Patch) (models.Subscription, error)
+	Delete(ctx context.Context, Id int64, version int64) error
+	Restore(ctx context.Context, Id int64) (models.Subscription, error)
+	History(ctx context.Context, Id int64) ([]models.SubscriptionChange, error)
+	List(ctx context.Context, filter models.ListFilter) (models.ListPage, error)
+	Sum(ctx context.Context, filter models.CostFilter, detail bool) (models.SumResult, error)
+	Report(ctx context.Context, filter models.CostFilter, groupBy []string) (models.CostReport, error)
+	Convert(totals []models.Money, currency string) (models.Money, error)
+	Batch(ctx context.Context, operations []models.BatchOperation, atomic bool) ([]storage.BatchResult, error)
+	Export(ctx context.Context, fn func(models.Subscription) error) error
 
 }
 
@@ -44,14 +57,23 @@ func New(
 
 // CreateSubscription godoc
 // @Summary      Create subscription
-// @Description  create subscription
+// @Description  create subscription. A request with an Idempotency-Key already used for another request fails with 422 idempotency_key_reused once the first has been answered, and one sent while the first is still running with 409 idempotency_key_in_use
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
+// @Param        Idempotency-Key  header  string  false  "makes retries return the first response instead of creating again"
 // @Param        input body requests.CreateSubscriptionRequest true "Subscription Info"
 // @Success      201  {object}  responses.CreateSubscriptionResponse
+// @Header       201  {string}  ETag  "version of the subscription"
+// @Header       201  {string}  Idempotent-Replayed  "true when the response is the one of an earlier request with the Idempotency-Key"
 // @Failure      400  {object}  httputil.Response
+// @Failure      409  {object}  httputil.Response
+// @Failure      422  {object}  httputil.Response
 // @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions [post]
 func (s *Subscription) CreateSubscription(log *slog.Logger) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -59,57 +81,44 @@ func (s *Subscription) CreateSubscription(log *slog.Logger) gin.HandlerFunc {
 		
 	var request requests.CreateSubscriptionRequest
 
-	log.With(
+	log := log.With(
 		slog.String("op", op),
-		slog.String("user_id", request.UserID),
+		requestid.Attr(ctx.Request.Context()),
 	)
 
-	err := ctx.BindJSON(&request)
+	err := ctx.ShouldBindJSON(&request)
 	if err != nil {
-		log.Error("failed to decode request body", sl.Err(err))
-
-		ctx.JSON(http.StatusBadRequest, httputil.Error("failed to decode request body"))
+		respondBindError(ctx, log, err)
 		
 		return 
 	}
 
-	log.Info("request body decoded", slog.Any("request", request))
-
-	
-	id, err := s.SubscriptionProvider.Create(request)
-	if errors.Is(err, storage.ErrSubscriptionExists) {
-			log.Error("subscription already exists", sl.Err(err))
-
-			ctx.JSON(http.StatusBadRequest, httputil.Error("subscription already exists"))
+	subscription, err := request.Subscription()
+	if err != nil {
+		respondError(ctx, log, "invalid subscription", err)
 
-			return 
+		return
 	}
 	
-	if errors.Is(err, storage.ErrInvalidStartDateFormat) {
-			log.Error("invalid start_date format", sl.Err(err))
-
-			ctx.JSON(http.StatusBadRequest, httputil.Error("invalid start_date format"))
-
-			return 
-	}
-
-
+	subscription, err = s.SubscriptionProvider.Create(ctx.Request.Context(), subscription)
 	if err != nil {
-		log.Error("failed to save subscription", sl.Err(err))
-
-		ctx.JSON(http.StatusBadRequest, httputil.Error("failed to save subscription"))
+		respondError(ctx, log, "failed to save subscription", err)
 
-		return 
+		return
 	}
 	
 
 	resp := responses.CreateSubscriptionResponse{
-		Id:          id,
-		ServiceName: request.ServiceName,
-		Price:       request.Price,
-		UserID:      request.UserID,
-		StartDate:   request.StartDate,
+		Id:          subscription.Id,
+		ServiceName: subscription.ServiceName,
+		Price:       subscription.Price,
+		UserID:      subscription.UserID,
+		StartDate:   subscription.StartDate,
+		EndDate:     subscription.EndDate,
+		Version:     subscription.Version,
 	}
+
+	setETag(ctx, subscription.Version)
 	ctx.JSON(http.StatusCreated, resp)
 	
 	}	
@@ -123,85 +132,154 @@ func (s *Subscription) CreateSubscription(log *slog.Logger) gin.HandlerFunc {
 // @Produce      json
 // @Param        id   path      int  true  "Subscription ID"
 // @Success      200  {object}  models.Subscription
+// @Header       200  {string}  ETag  "version of the subscription, for If-Match"
 // @Failure      400  {object}  httputil.Response
+// @Failure      404  {object}  httputil.Response
+// @Failure      504  {object}  httputil.Response
 // @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions/{id} [get]
 func (s *Subscription) ReadSubscription(log *slog.Logger) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 	const op = "http-server.handlers.ReadSubscription"
 		
-	log.With(
+	log := log.With(
 		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
 	)
 	
 	subscriptionId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-
-		ctx.JSON(http.StatusBadRequest, httputil.Error("Could not parse subscription id"))
+		respondInvalidID(ctx, log, err)
 
 		return
 	}
-	
-	subscription, err := s.SubscriptionProvider.Read(subscriptionId) 
-	if errors.Is(err, storage.ErrSubscriptionNotFound) {
-		log.Error("subscription not found", sl.Err(err))
 
-		ctx.JSON(http.StatusBadRequest, httputil.Error("subscription not found"))
+	subscription, err := s.SubscriptionProvider.Read(ctx.Request.Context(), subscriptionId) 
+	if err != nil {
+		respondError(ctx, log, "failed to read subscription", err)
 
 		return
 	}
-	
-	if  err != nil {
-		log.Error("internal server error", sl.Err(err))
-
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("internal server error"))
-
-		return 
-	}
 
+	setETag(ctx, subscription.Version)
 	ctx.JSON(http.StatusOK, subscription)
 	}	
 }
 
 // ListSubscription godoc
 // @Summary      Show subscriptions
-// @Description  show list of subscriptions
+// @Description  show a page of subscriptions filtered by the query parameters
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
-// @Success      200  {object}  []models.Subscription
+// @Param        user_id          query     string  false  "User ID"
+// @Param        service_name     query     string  false  "Service name"
+// @Param        currency         query     string  false  "ISO 4217 currency"
+// @Param        start_date_from  query     string  false  "Earliest start date, MM-YYYY"
+// @Param        start_date_to    query     string  false  "Latest start date, MM-YYYY"
+// @Param        min_price        query     int     false  "Minimum price in minor units"
+// @Param        max_price        query     int     false  "Maximum price in minor units"
+// @Param        sort             query     string  false  "Sort by id, price or start_date, prefix with - for descending"
+// @Param        limit            query     int     false  "Page size, 100 by default"
+// @Param        offset           query     int     false  "Number of subscriptions to skip"
+// @Param        cursor           query     string  false  "next_cursor of the previous page, sent with the same sort"
+// @Param        include_total    query     bool    false  "Also count the subscriptions on all pages"
+// @Param        include_deleted  query     bool    false  "Also list deleted subscriptions"
+// @Success      200  {object}  responses.ListSubscriptionsResponse
+// @Failure      400  {object}  httputil.Response
+// @Failure      422  {object}  httputil.Response
+// @Failure      504  {object}  httputil.Response
 // @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions [get]
 func (s *Subscription) ListSubscription(log *slog.Logger) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 	const op = "http-server.handlers.ListSubscription"
+
+	var request requests.ListSubscriptionsRequest
 		
-	log.With(
+	log := log.With(
 		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
 	)
 
-	subscriptions, err := s.SubscriptionProvider.List() 
-	if  err!= nil {
-		log.Error("internal server error", sl.Err(err))	
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("internal server error"))
-		return 
+	err := ctx.ShouldBindQuery(&request)
+	if err != nil {
+		respondBindError(ctx, log, err)
+
+		return
+	}
+
+	filter, err := request.Filter()
+	if err != nil {
+		respondError(ctx, log, "invalid filter", err)
+
+		return
 	}
 
-	ctx.JSON(http.StatusOK, subscriptions)
+	if request.Cursor != "" {
+		after, err := decodeCursor(request.Cursor, request.Sort)
+		if err != nil {
+			log.Warn("invalid cursor", sl.Err(err))
+
+			httputil.Abort(ctx, http.StatusBadRequest, httputil.Error(httputil.CodeInvalidRequest, "invalid cursor"))
+
+			return
+		}
+
+		filter.After = &after
+		filter.Offset = 0
+	}
+
+	page, err := s.SubscriptionProvider.List(ctx.Request.Context(), filter) 
+	if err != nil {
+		respondError(ctx, log, "failed to list subscriptions", err)
+
+		return
+	}
+
+	resp := responses.ListSubscriptionsResponse{
+		Subscriptions: page.Subscriptions,
+		Total:         page.Total,
+	}
+
+	if page.Next != nil {
+		resp.NextCursor = encodeCursor(request.Sort, *page.Next)
+	}
+
+	ctx.JSON(http.StatusOK, resp)
 	}	
 }
 
 // UpdateSubscription godoc
 // @Summary      Update subscription
-// @Description  update subscription by id
+// @Description  replace every field of subscription by id
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        id   path      int  true  "Subscription ID"
+// @Param        If-Match  header  string  true  "ETag of the subscription as read, or * to overwrite any version"
 // @Param        input body requests.UpdateSubscriptionRequest true "subscription info"
 // @Success      200  {object}  responses.CreateSubscriptionResponse
+// @Header       200  {string}  ETag  "new version of the subscription"
 // @Failure      400  {object}  httputil.Response
+// @Failure      404  {object}  httputil.Response
+// @Failure      409  {object}  httputil.Response
+// @Failure      412  {object}  httputil.Response
+// @Failure      422  {object}  httputil.Response
+// @Failure      428  {object}  httputil.Response
 // @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions/{id} [put]
 func (s *Subscription) UpdateSubscription(log *slog.Logger) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -209,201 +287,550 @@ func (s *Subscription) UpdateSubscription(log *slog.Logger) gin.HandlerFunc {
 
 	var request requests.UpdateSubscriptionRequest
 
-	log.With(
+	log := log.With(
 		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
 	)
 
 	subscriptionId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		
-		ctx.JSON(http.StatusBadRequest, httputil.Error("Could not parse subscription id"))
+		respondInvalidID(ctx, log, err)
 
 		return
 	}
 
-	err = ctx.BindJSON(&request)
-	if err != nil {
-		log.Error("failed to decode request body", sl.Err(err))
+	version, ok := ifMatch(ctx, log)
+	if !ok {
+		return
+	}
 
-		ctx.JSON(http.StatusBadRequest, httputil.Error("failed to decode request body"))
+	err = ctx.ShouldBindJSON(&request)
+	if err != nil {
+		respondBindError(ctx, log, err)
 
 		return 
 	}
 
-	log.Info("request body decoded", slog.Any("request", request))
+	subscription, err := request.Subscription()
+	if err != nil {
+		respondError(ctx, log, "invalid subscription", err)
+
+		return
+	}
 	
-	id, err := s.SubscriptionProvider.Update(request, subscriptionId)
-	if errors.Is(err, storage.ErrSubscriptionExists) {
+	subscription, err = s.SubscriptionProvider.Update(ctx.Request.Context(), subscriptionId, version, subscription)
+	if err != nil {
+		respondError(ctx, log, "failed to update subscription", err)
 
-		log.Error("subscription already exists", sl.Err(err))
+		return
+	}
 
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("subscription already exists"))
-	
-		return 
+	resp := responses.CreateSubscriptionResponse{
+		Id:          subscription.Id,
+		ServiceName: subscription.ServiceName,
+		Price:       subscription.Price,
+		UserID:      subscription.UserID,
+		StartDate:   subscription.StartDate,
+		EndDate:     subscription.EndDate,
+		Version:     subscription.Version,
 	}
 
-	if errors.Is(err, storage.ErrInvalidStartDateFormat) {
-		
-		log.Error("invalid start_date format", sl.Err(err))
+	setETag(ctx, subscription.Version)
+	ctx.JSON(http.StatusOK, resp)
 
-		ctx.JSON(http.StatusBadRequest, httputil.Error("invalid start_date format"))
+	}	
+}
 
-		return 
-	}
+// PatchSubscription godoc
+// @Summary      Patch subscription
+// @Description  change only the fields present in the body, an empty end_date makes the subscription open-ended
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Subscription ID"
+// @Param        If-Match  header  string  true  "ETag of the subscription as read, or * to patch any version"
+// @Param        input body requests.PatchSubscriptionRequest true "fields to change"
+// @Success      200  {object}  models.Subscription
+// @Header       200  {string}  ETag  "new version of the subscription"
+// @Failure      400  {object}  httputil.Response
+// @Failure      404  {object}  httputil.Response
+// @Failure      409  {object}  httputil.Response
+// @Failure      412  {object}  httputil.Response
+// @Failure      422  {object}  httputil.Response
+// @Failure      428  {object}  httputil.Response
+// @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/{id} [patch]
+func (s *Subscription) PatchSubscription(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.PatchSubscription"
 
+	var request requests.PatchSubscriptionRequest
 
-	if errors.Is(err, storage.ErrSubscriptionNotFound) {
-	
-		log.Error("subscription not found", sl.Err(err))
-	
-		ctx.JSON(http.StatusBadRequest, httputil.Error("subscription not found"))
-		
-		return 
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	subscriptionId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		respondInvalidID(ctx, log, err)
+
+		return
 	}
 
+	version, ok := ifMatch(ctx, log)
+	if !ok {
+		return
+	}
 
+	err = ctx.ShouldBindJSON(&request)
 	if err != nil {
-	
-		log.Error("failed to update subscription", sl.Err(err))
-		
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("failed to update subscription"))
+		respondBindError(ctx, log, err)
 
-		return 
+		return
 	}
 
-	resp := responses.CreateSubscriptionResponse{
-		Id:          id,
-		ServiceName: request.ServiceName,
-		Price:       request.Price,
-		UserID:      request.UserID,
-		StartDate:   request.StartDate,
+	patch, err := request.Patch()
+	if err != nil {
+		respondError(ctx, log, "invalid patch", err)
+
+		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	subscription, err := s.SubscriptionProvider.Patch(ctx.Request.Context(), subscriptionId, version, patch)
+	if err != nil {
+		respondError(ctx, log, "failed to patch subscription", err)
 
-	}	
+		return
+	}
+
+	setETag(ctx, subscription.Version)
+	ctx.JSON(http.StatusOK, subscription)
+	}
 }
 
 // DeleteSubscription godoc
 // @Summary      Delete subscription
-// @Description  delete subscription by id
+// @Description  soft-delete subscription by id, it is hidden from reads until restored
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        id   path      int  true  "Subscription ID"
+// @Param        If-Match  header  string  true  "ETag of the subscription as read, or * to delete any version"
 // @Success      200  {object}  responses.DeleteSubscriptionResponse
 // @Failure      400  {object}  httputil.Response
+// @Failure      404  {object}  httputil.Response
+// @Failure      412  {object}  httputil.Response
+// @Failure      428  {object}  httputil.Response
 // @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions/{id} [delete]
 func (s *Subscription) DeleteSubscription(log *slog.Logger) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 	const op = "http-server.handlers.DeleteSubscription"
 		
-	log.With(
+	log := log.With(
 		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
 	)
 
 	subscriptionId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
+		respondInvalidID(ctx, log, err)
 
-		log.Error("Could not parse subscription id", sl.Err(err))
+		return
+	}
 
-		ctx.JSON(http.StatusBadRequest, httputil.Error("Could not parse subscription id"))
+	version, ok := ifMatch(ctx, log)
+	if !ok {
+		return
+	}
+
+	err = s.SubscriptionProvider.Delete(ctx.Request.Context(), subscriptionId, version)
+	if err != nil {
+		respondError(ctx, log, "failed to delete subscription", err)
 
 		return
 	}
+	resp := responses.DeleteSubscriptionResponse{
+		Message: "subscription deleted successfully",
+		Id: subscriptionId,
+	}
 
+	ctx.JSON(http.StatusOK, resp)
+	}	
+}
 
-	id, err := s.SubscriptionProvider.Delete(subscriptionId)
-	
-	if errors.Is(err, storage.ErrSubscriptionNotFound) {
 
-		log.Error("subscription not found", sl.Err(err))
-		
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("subscription not found"))
+// RestoreSubscription godoc
+// @Summary      Restore subscription
+// @Description  restore a deleted subscription by id
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Subscription ID"
+// @Success      200  {object}  models.Subscription
+// @Header       200  {string}  ETag  "new version of the subscription"
+// @Failure      400  {object}  httputil.Response
+// @Failure      404  {object}  httputil.Response
+// @Failure      409  {object}  httputil.Response
+// @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/{id}/restore [post]
+func (s *Subscription) RestoreSubscription(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.RestoreSubscription"
+
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	subscriptionId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		respondInvalidID(ctx, log, err)
 
 		return
 	}
 
-	if err != nil  {
-		log.Error("failed to delete subscription", sl.Err(err))
-
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("failed to delete subscription"))
+	subscription, err := s.SubscriptionProvider.Restore(ctx.Request.Context(), subscriptionId)
+	if err != nil {
+		respondError(ctx, log, "failed to restore subscription", err)
 
 		return
+	}
 
+	setETag(ctx, subscription.Version)
+	ctx.JSON(http.StatusOK, subscription)
 	}
-	resp := responses.DeleteSubscriptionResponse{
-		Message: "subscription deleted successfully",
-		Id: id,
+}
+
+// SubscriptionHistory godoc
+// @Summary      Subscription history
+// @Description  list every create, update, delete and restore of a subscription, oldest first
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Subscription ID"
+// @Success      200  {object}  []models.SubscriptionChange
+// @Failure      400  {object}  httputil.Response
+// @Failure      404  {object}  httputil.Response
+// @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/{id}/history [get]
+func (s *Subscription) SubscriptionHistory(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.SubscriptionHistory"
+
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	subscriptionId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		respondInvalidID(ctx, log, err)
+
+		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
-	}	
-}
+	changes, err := s.SubscriptionProvider.History(ctx.Request.Context(), subscriptionId)
+	if err != nil {
+		respondError(ctx, log, "failed to read subscription history", err)
 
+		return
+	}
+
+	ctx.JSON(http.StatusOK, changes)
+	}
+}
 
 
 // SumSubscription godoc
 // @Summary     Sum subscriptions
-// @Description  sum price of all subscriptions for the selected period filtered by user_id and service_name
+// @Description  total cost of the subscriptions for the selected period, counting the monthly price once for every month a subscription overlaps it. user_id and service_name are optional filters
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        input body requests.SumSubscriptionRequest true "Subscription Info"
+// @Param        detail query bool false "Include the cost of every contributing subscription"
+// @Param        convert_to query string false "ISO 4217 currency to convert the totals to"
 // @Success      200  {object}  responses.SumSubscriptionResponse
 // @Failure      400  {object}  httputil.Response
+// @Failure      422  {object}  httputil.Response
+// @Failure      504  {object}  httputil.Response
 // @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions/sum [post]
 func (s *Subscription) SumSubscriptions(log *slog.Logger) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-	const op = "http-server.handlers.DeleteSubscription"
+	const op = "http-server.handlers.SumSubscriptions"
 
 	var request requests.SumSubscriptionRequest
 
-	log.With(
+	log := log.With(
 		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
 	)
 
-	err := ctx.BindJSON(&request)
+	err := ctx.ShouldBindJSON(&request)
 	if err != nil {
-		log.Error("failed to decode request body", sl.Err(err))
-
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("failed to decode request body"))
+		respondBindError(ctx, log, err)
 
 		return 
 	}
 
-	totalSum, err := s.SubscriptionProvider.Sum(request)
-	if errors.Is(err, storage.ErrUnableToCalculateSum) {
-		log.Error("unable to calculate sum", sl.Err(err))
-
-		ctx.JSON(http.StatusInternalServerError, httputil.Error("unable to calculate sum"))
+	err = ctx.ShouldBindQuery(&request)
+	if err != nil {
+		respondBindError(ctx, log, err)
 
 		return
 	}
 
-	if errors.Is(err, storage.ErrInvalidStartDateFormat) {
-		log.Error("invalid start_date format", sl.Err(err))
-
-		ctx.JSON(http.StatusBadRequest, httputil.Error("invalid start_date format"))
+	filter, err := request.Filter()
+	if err != nil {
+		respondError(ctx, log, "invalid period", err)
 
-		return 
+		return
 	}
-	
-	if errors.Is(err, storage.ErrInvalidEndDateFormat) {
-		log.Error("invalid end_date format", sl.Err(err))
 
-		ctx.JSON(http.StatusBadRequest, httputil.Error("invalid end_date format"))
+	result, err := s.SubscriptionProvider.Sum(ctx.Request.Context(), filter, request.Detail)
+	if err != nil {
+		respondError(ctx, log, "failed to calculate sum", err)
 
-		return 
+		return
 	}
 
 	resp := responses.SumSubscriptionResponse{
-		TotalSum: totalSum,
+		Totals:        result.Totals,
+		Count:         result.Count,
+		Subscriptions: result.Subscriptions,
+	}
+
+	if request.ConvertTo != "" {
+		var ok bool
+		resp.Converted, ok = s.convert(ctx, log, resp.Totals, request.ConvertTo)
+		if !ok {
+			return
+		}
 	}
+
 	ctx.JSON(http.StatusOK, resp)
 
 	}	
 }
 
+
+
+// ReportSubscriptions godoc
+// @Summary      Cost report
+// @Description  cost of subscriptions for the selected period, optionally filtered by user_id and service_name and grouped by any combination of service_name, user_id and month
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        input body requests.ReportSubscriptionsRequest true "Report parameters"
+// @Param        convert_to query string false "ISO 4217 currency to convert the totals to"
+// @Success      200  {object}  responses.ReportSubscriptionsResponse
+// @Failure      400  {object}  httputil.Response
+// @Failure      422  {object}  httputil.Response
+// @Failure      504  {object}  httputil.Response
+// @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/report [post]
+func (s *Subscription) ReportSubscriptions(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.ReportSubscriptions"
+
+	var request requests.ReportSubscriptionsRequest
+
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		respondBindError(ctx, log, err)
+
+		return
+	}
+
+	err = ctx.ShouldBindQuery(&request)
+	if err != nil {
+		respondBindError(ctx, log, err)
+
+		return
+	}
+
+	filter, err := request.Filter()
+	if err != nil {
+		respondError(ctx, log, "invalid period", err)
+
+		return
+	}
+
+	report, err := s.SubscriptionProvider.Report(ctx.Request.Context(), filter, request.GroupBy)
+	if err != nil {
+		respondError(ctx, log, "failed to build report", err)
+
+		return
+	}
+
+	resp := responses.ReportSubscriptionsResponse{
+		GroupBy: request.GroupBy,
+		Groups:  report.Groups,
+		Totals:  report.Totals,
+	}
+	if resp.GroupBy == nil {
+		resp.GroupBy = []string{}
+	}
+
+	if request.ConvertTo != "" {
+		var ok bool
+		resp.Converted, ok = s.convert(ctx, log, resp.Totals, request.ConvertTo)
+		if !ok {
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+
+// BatchSubscriptions godoc
+// @Summary      Batch subscriptions
+// @Description  create, update and delete subscriptions in one transaction. In atomic mode (the default) any failure rolls back the whole batch, in partial mode only the failed operations are skipped. An update or delete needs the version of the subscription, or force to run at any version; it fails with precondition_failed without either or when the subscription is no longer at that version. A request with an Idempotency-Key already used for another request fails with 422 idempotency_key_reused once the first has been answered, and one sent while the first is still running with 409 idempotency_key_in_use
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key  header  string  false  "makes retries return the first response instead of running the batch again"
+// @Param        input body requests.BatchSubscriptionRequest true "Batch operations"
+// @Success      200  {object}  responses.BatchSubscriptionResponse
+// @Header       200  {string}  Idempotent-Replayed  "true when the response is the one of an earlier request with the Idempotency-Key"
+// @Failure      400  {object}  httputil.Response
+// @Failure      409  {object}  httputil.Response
+// @Failure      422  {object}  responses.BatchSubscriptionResponse
+// @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/batch [post]
+func (s *Subscription) BatchSubscriptions(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.BatchSubscriptions"
+
+	var request requests.BatchSubscriptionRequest
+
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		respondBindError(ctx, log, err)
+
+		return
+	}
+
+	operations, err := request.BatchOperations()
+	if err != nil {
+		respondError(ctx, log, "invalid batch", err)
+
+		return
+	}
+
+	results, err := s.SubscriptionProvider.Batch(ctx.Request.Context(), operations, request.Atomic())
+	if err != nil && !errors.Is(err, storage.ErrBatchAborted) {
+		respondError(ctx, log, "failed to apply batch", err)
+
+		return
+	}
+
+	aborted := errors.Is(err, storage.ErrBatchAborted)
+
+	resp := responses.BatchSubscriptionResponse{
+		Applied: !aborted,
+		Results: make([]responses.BatchItemResult, len(results)),
+	}
+
+	for i, result := range results {
+		item := responses.BatchItemResult{
+			Index:  i,
+			Op:     result.Op,
+			Id:     result.ID,
+			Status: "ok",
+		}
+
+		switch {
+		case result.Err != nil:
+			item.Status = "failed"
+			item.Code = apierror.Lookup(result.Err).Code
+			item.Error = batchErrorMessage(result.Err)
+		case aborted:
+			item.Id = 0
+			item.Status = "rolled_back"
+		}
+
+		resp.Results[i] = item
+	}
+
+	if aborted {
+		log.Warn("batch rolled back", sl.Err(err))
+
+		ctx.JSON(apierror.Lookup(err).Status, resp)
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+
+// batchErrorMessage describes why a batch operation failed without exposing
+// internal errors to the caller. Invalid operations keep the detail of what
+// is missing.
+func batchErrorMessage(err error) string {
+	if errors.Is(err, service.ErrInvalidBatchOperation) {
+		return err.Error()
+	}
+
+	return apierror.Lookup(err).Message
+}
+
+
+// convert sums totals in currency. It writes the error response and returns
+// false when that fails.
+func (s *Subscription) convert(ctx *gin.Context, log *slog.Logger, totals []models.Money, currency string) (*models.Money, bool) {
+	converted, err := s.SubscriptionProvider.Convert(totals, currency)
+	if err != nil {
+		respondError(ctx, log, "failed to convert totals", err)
+
+		return nil, false
+	}
+
+	return &converted, true
+}
+
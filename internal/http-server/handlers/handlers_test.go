@@ -0,0 +1,523 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/config"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/handlers"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/auth"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/idempotency"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/authn"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/money"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/validation"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage/memory"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/responses"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	adminKey = "admin-key"
+	aliceKey = "alice-key"
+	bobKey   = "bob-key"
+
+	alice = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+	bob   = "7b3e2a10-5c4d-4e8f-9a1b-2c3d4e5f6a7b"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	if err := validation.Register(); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+
+// newRouter routes the subscription handlers over a fresh memory storage the
+// way main does.
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	log := slog.New(slog.DiscardHandler)
+
+	rates, err := money.NewTable("RUB", map[string]string{"USD": "92.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := memory.New()
+	h := handlers.New(service.New(storage, rates))
+
+	authenticator := authn.New(config.Auth{APIKeys: config.APIKeys{
+		{Key: adminKey, Subject: "admin", Role: models.RoleAdmin},
+		{Key: aliceKey, Subject: alice, Role: models.RoleUser},
+		{Key: bobKey, Subject: bob, Role: models.RoleUser},
+	}})
+	idempotent := idempotency.New(log, storage, config.Idempotency{TTL: time.Hour})
+
+	router := gin.New()
+	router.Use(requestid.Middleware(), httputil.Recovery(log), auth.Middleware(log, authenticator))
+
+	router.POST("/subscriptions", idempotent, h.CreateSubscription(log))
+	router.GET("/subscriptions/:id", h.ReadSubscription(log))
+	router.PUT("/subscriptions/:id", h.UpdateSubscription(log))
+	router.PATCH("/subscriptions/:id", h.PatchSubscription(log))
+	router.DELETE("/subscriptions/:id", h.DeleteSubscription(log))
+	router.GET("/subscriptions", h.ListSubscription(log))
+	router.POST("/subscriptions/import", idempotent, h.ImportSubscriptions(log))
+	router.POST("/subscriptions/batch", h.BatchSubscriptions(log))
+	router.GET("/subscriptions/export", h.ExportSubscriptions(log))
+
+	return router
+}
+
+
+// do sends a request as the holder of key, without credentials when key is
+// empty, and returns the recorded response.
+func do(router http.Handler, method string, path string, key string, body string, header map[string]string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	request := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		request.Header.Set(auth.HeaderAPIKey, key)
+	}
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+
+func subscription(serviceName string, userID string) string {
+	return `{"service_name":"` + serviceName + `","price":{"amount":40000,"currency":"RUB"},"user_id":"` + userID + `","start_date":"07-2025"}`
+}
+
+
+// create creates a subscription as the holder of key and returns its id.
+func create(t *testing.T, router http.Handler, key string, body string) int64 {
+	t.Helper()
+
+	recorder := do(router, http.MethodPost, "/subscriptions", key, body, nil)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d: %s", recorder.Code, http.StatusCreated, recorder.Body)
+	}
+
+	var created responses.CreateSubscriptionResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	return created.Id
+}
+
+
+func path(id int64) string {
+	return "/subscriptions/" + strconv.FormatInt(id, 10)
+}
+
+
+func errorCode(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var response httputil.Response
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("error body %q: %v", recorder.Body, err)
+	}
+
+	return response.Code
+}
+
+
+func TestErrorCodes(t *testing.T) {
+	router := newRouter(t)
+	id := create(t, router, adminKey, subscription("Yandex Plus", alice))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "no credentials", method: http.MethodGet, path: path(id), status: http.StatusUnauthorized, code: httputil.CodeUnauthorized},
+		{name: "unknown key", method: http.MethodGet, path: path(id), key: "nope", status: http.StatusUnauthorized, code: httputil.CodeUnauthorized},
+		{name: "invalid id", method: http.MethodGet, path: "/subscriptions/abc", key: adminKey, status: http.StatusBadRequest, code: httputil.CodeInvalidRequest},
+		{name: "missing", method: http.MethodGet, path: path(id + 100), key: adminKey, status: http.StatusNotFound, code: httputil.CodeNotFound},
+		{name: "duplicate", method: http.MethodPost, path: "/subscriptions", key: adminKey, body: subscription("Yandex Plus", alice), status: http.StatusConflict, code: httputil.CodeConflict},
+		{name: "invalid body", method: http.MethodPost, path: "/subscriptions", key: adminKey, body: subscription("Yandex Plus", "nope"), status: http.StatusUnprocessableEntity, code: httputil.CodeValidationFailed},
+		{name: "invalid query", method: http.MethodGet, path: "/subscriptions?limit=1001", key: adminKey, status: http.StatusUnprocessableEntity, code: httputil.CodeValidationFailed},
+		{name: "empty batch", method: http.MethodPost, path: "/subscriptions/batch", key: adminKey, body: `{"operations":[]}`, status: http.StatusUnprocessableEntity, code: httputil.CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := do(router, tt.method, tt.path, tt.key, tt.body, nil)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.status, recorder.Body)
+			}
+			if code := errorCode(t, recorder); code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+		})
+	}
+}
+
+
+func TestScoping(t *testing.T) {
+	router := newRouter(t)
+	alices := create(t, router, aliceKey, subscription("Yandex Plus", alice))
+	bobs := create(t, router, bobKey, subscription("Kinopoisk", bob))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "reads own", method: http.MethodGet, path: path(alices), key: aliceKey, status: http.StatusOK},
+		// Someone else's subscription is reported as missing, so ids can't be probed.
+		{name: "reads another's", method: http.MethodGet, path: path(bobs), key: aliceKey, status: http.StatusNotFound, code: httputil.CodeNotFound},
+		{name: "admin reads any", method: http.MethodGet, path: path(bobs), key: adminKey, status: http.StatusOK},
+		{name: "creates for another", method: http.MethodPost, path: "/subscriptions", key: aliceKey, body: subscription("Okko", bob), status: http.StatusForbidden, code: httputil.CodeForbidden},
+		{name: "lists another's", method: http.MethodGet, path: "/subscriptions?user_id=" + bob, key: aliceKey, status: http.StatusForbidden, code: httputil.CodeForbidden},
+		{name: "updates into another's", method: http.MethodPut, path: path(alices), key: aliceKey, body: subscription("Yandex Plus", bob), status: http.StatusForbidden, code: httputil.CodeForbidden},
+		{name: "deletes another's", method: http.MethodDelete, path: path(bobs), key: aliceKey, status: http.StatusNotFound, code: httputil.CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := do(router, tt.method, tt.path, tt.key, tt.body, map[string]string{"If-Match": "*"})
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.status, recorder.Body)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, recorder); code != tt.code {
+					t.Errorf("code = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+
+	t.Run("lists own", func(t *testing.T) {
+		recorder := do(router, http.MethodGet, "/subscriptions?include_total=true", aliceKey, "", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+
+		var list responses.ListSubscriptionsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		if list.Total == nil || *list.Total != 1 || len(list.Subscriptions) != 1 || list.Subscriptions[0].UserID != alice {
+			t.Errorf("list = %+v, want only the subscription of %s", list, alice)
+		}
+	})
+
+	t.Run("exports own", func(t *testing.T) {
+		recorder := do(router, http.MethodGet, "/subscriptions/export?format=ndjson", aliceKey, "", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+
+		if lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], alice) {
+			t.Errorf("export = %q, want only the subscription of %s", recorder.Body, alice)
+		}
+	})
+
+	t.Run("batches another's", func(t *testing.T) {
+		body := `{"mode":"partial","operations":[{"op":"delete","id":` + strconv.FormatInt(bobs, 10) + `,"force":true},{"op":"create","subscription":` + subscription("Okko", bob) + `}]}`
+
+		recorder := do(router, http.MethodPost, "/subscriptions/batch", aliceKey, body, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+
+		var batch responses.BatchSubscriptionResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &batch); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{httputil.CodeNotFound, httputil.CodeForbidden}
+		if len(batch.Results) != len(want) {
+			t.Fatalf("results = %+v, want %d", batch.Results, len(want))
+		}
+		for i, result := range batch.Results {
+			if result.Code != want[i] {
+				t.Errorf("result %d code = %q, want %q", i, result.Code, want[i])
+			}
+		}
+
+		if do(router, http.MethodGet, path(bobs), adminKey, "", nil).Code != http.StatusOK {
+			t.Errorf("subscription of %s was deleted", bob)
+		}
+	})
+}
+
+
+func TestListPages(t *testing.T) {
+	router := newRouter(t)
+
+	priced := func(serviceName string, amount int) string {
+		return `{"service_name":"` + serviceName + `","price":{"amount":` + strconv.Itoa(amount) + `,"currency":"RUB"},"user_id":"` + alice + `","start_date":"07-2025"}`
+	}
+
+	for i, amount := range []int{300, 500, 400} {
+		create(t, router, aliceKey, priced("Service "+strconv.Itoa(i), amount))
+	}
+
+	list := func(query string) responses.ListSubscriptionsResponse {
+		t.Helper()
+
+		recorder := do(router, http.MethodGet, "/subscriptions?"+query, aliceKey, "", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+
+		var page responses.ListSubscriptionsResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+
+		return page
+	}
+
+	first := list("sort=-price&limit=2")
+	if first.Total != nil || first.NextCursor == "" || len(first.Subscriptions) != 2 {
+		t.Fatalf("first page = %+v, want 2 subscriptions, a cursor and no total", first)
+	}
+
+	// A subscription sorted onto the first page must not shift the second.
+	create(t, router, aliceKey, priced("Service 3", 900))
+
+	second := list("sort=-price&limit=2&cursor=" + first.NextCursor)
+
+	var prices []int64
+	for _, subscription := range append(first.Subscriptions, second.Subscriptions...) {
+		prices = append(prices, subscription.Price.Amount)
+	}
+	if !slices.Equal(prices, []int64{500, 400, 300}) || second.NextCursor != "" {
+		t.Errorf("prices = %v, next cursor %q, want [500 400 300] and none", prices, second.NextCursor)
+	}
+
+	if total := list("include_total=true").Total; total == nil || *total != 4 {
+		t.Errorf("total = %v, want 4", total)
+	}
+
+	if recorder := do(router, http.MethodGet, "/subscriptions?sort=price&cursor="+first.NextCursor, aliceKey, "", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("cursor of another sort: status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+
+func TestPreconditions(t *testing.T) {
+	router := newRouter(t)
+
+	created := do(router, http.MethodPost, "/subscriptions", adminKey, subscription("Yandex Plus", alice), nil)
+	if etag := created.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("create ETag = %q, want %q", etag, `"1"`)
+	}
+
+	var subscription responses.CreateSubscriptionResponse
+	if err := json.Unmarshal(created.Body.Bytes(), &subscription); err != nil {
+		t.Fatal(err)
+	}
+	id := subscription.Id
+
+	read := do(router, http.MethodGet, path(id), adminKey, "", nil)
+	if etag := read.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("read ETag = %q, want %q", etag, `"1"`)
+	}
+
+	patch := `{"service_name":"Yandex Plus Multi"}`
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		status  int
+		code    string
+		etag    string
+	}{
+		{name: "without If-Match", status: http.StatusPreconditionRequired, code: httputil.CodePreconditionRequired},
+		{name: "unknown ETag", ifMatch: "one", status: http.StatusPreconditionFailed, code: httputil.CodePreconditionFailed},
+		{name: "current version", ifMatch: `"1"`, status: http.StatusOK, etag: `"2"`},
+		{name: "stale version", ifMatch: `"1"`, status: http.StatusPreconditionFailed, code: httputil.CodePreconditionFailed},
+		{name: "any version", ifMatch: "*", status: http.StatusOK, etag: `"3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{}
+			if tt.ifMatch != "" {
+				header["If-Match"] = tt.ifMatch
+			}
+
+			recorder := do(router, http.MethodPatch, path(id), adminKey, patch, header)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.status, recorder.Body)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, recorder); code != tt.code {
+					t.Errorf("code = %q, want %q", code, tt.code)
+				}
+			}
+			if etag := recorder.Header().Get("ETag"); etag != tt.etag {
+				t.Errorf("ETag = %q, want %q", etag, tt.etag)
+			}
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		operation := `{"op":"delete","id":` + strconv.FormatInt(id, 10)
+		body := `{"mode":"partial","operations":[` + operation + `},` + operation + `,"version":1},` + operation + `,"version":3,"force":true}]}`
+
+		recorder := do(router, http.MethodPost, "/subscriptions/batch", adminKey, body, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+
+		var batch responses.BatchSubscriptionResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &batch); err != nil {
+			t.Fatal(err)
+		}
+
+		// Neither version nor force, a stale version, both.
+		want := []string{httputil.CodePreconditionFailed, httputil.CodePreconditionFailed, "invalid_batch_operation"}
+		if len(batch.Results) != len(want) {
+			t.Fatalf("results = %+v, want %d", batch.Results, len(want))
+		}
+		for i, result := range batch.Results {
+			if result.Code != want[i] {
+				t.Errorf("result %d code = %q, want %q", i, result.Code, want[i])
+			}
+		}
+	})
+}
+
+
+func TestIdempotentCreate(t *testing.T) {
+	router := newRouter(t)
+	key := map[string]string{idempotency.HeaderKey: "create-1"}
+	body := subscription("Yandex Plus", alice)
+
+	first := do(router, http.MethodPost, "/subscriptions", aliceKey, body, key)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d, want %d: %s", first.Code, http.StatusCreated, first.Body)
+	}
+	if first.Header().Get(idempotency.HeaderReplayed) != "" {
+		t.Errorf("first: %s set on a response that was not replayed", idempotency.HeaderReplayed)
+	}
+
+	retry := do(router, http.MethodPost, "/subscriptions", aliceKey, body, key)
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("retry: status = %d, want %d: %s", retry.Code, http.StatusCreated, retry.Body)
+	}
+	if retry.Header().Get(idempotency.HeaderReplayed) != "true" {
+		t.Errorf("retry: %s = %q, want \"true\"", idempotency.HeaderReplayed, retry.Header().Get(idempotency.HeaderReplayed))
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("retry body = %s, want the first one %s", retry.Body, first.Body)
+	}
+	if retry.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("retry ETag = %q, want %q", retry.Header().Get("ETag"), first.Header().Get("ETag"))
+	}
+
+	reused := do(router, http.MethodPost, "/subscriptions", aliceKey, subscription("Kinopoisk", alice), key)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused: status = %d, want %d: %s", reused.Code, http.StatusUnprocessableEntity, reused.Body)
+	}
+	if code := errorCode(t, reused); code != httputil.CodeIdempotencyKeyReused {
+		t.Errorf("reused: code = %q, want %q", code, httputil.CodeIdempotencyKeyReused)
+	}
+
+	list := do(router, http.MethodGet, "/subscriptions", aliceKey, "", nil)
+
+	var subscriptions responses.ListSubscriptionsResponse
+	if err := json.Unmarshal(list.Body.Bytes(), &subscriptions); err != nil {
+		t.Fatal(err)
+	}
+	if len(subscriptions.Subscriptions) != 1 {
+		t.Errorf("%d subscriptions after a retried create, want 1", len(subscriptions.Subscriptions))
+	}
+}
+
+
+func TestImportNDJSON(t *testing.T) {
+	router := newRouter(t)
+
+	long := `{"service_name":"` + strings.Repeat("a", 2<<20) + `"}`
+	body := strings.Join([]string{
+		subscription("Yandex Plus", alice),
+		long,
+		`{"service_name":`,
+		"",
+		subscription("Kinopoisk", alice),
+	}, "\n")
+
+	// The body is hashed as it is read, so a large import may carry a key.
+	key := map[string]string{idempotency.HeaderKey: "import-1"}
+
+	recorder := do(router, http.MethodPost, "/subscriptions/import?format=ndjson", adminKey, body, key)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+
+	var imported responses.ImportSubscriptionsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &imported); err != nil {
+		t.Fatal(err)
+	}
+
+	if imported.Imported != 2 || imported.Failed != 2 || len(imported.Errors) != 2 {
+		t.Fatalf("import = %+v, want 2 imported and 2 failed", imported)
+	}
+	if line := imported.Errors[0]; line.Line != 2 || !strings.Contains(line.Error, "longer than") {
+		t.Errorf("first error = %+v, want line 2 reported as too long", line)
+	}
+	if line := imported.Errors[1]; line.Line != 3 || line.Error != "invalid json" {
+		t.Errorf("second error = %+v, want line 3 reported as invalid json", line)
+	}
+
+	retry := do(router, http.MethodPost, "/subscriptions/import?format=ndjson", adminKey, body, key)
+	if retry.Header().Get(idempotency.HeaderReplayed) != "true" || retry.Body.String() != recorder.Body.String() {
+		t.Errorf("retry = %d %s, want the first response replayed", retry.Code, retry.Body)
+	}
+
+	reused := do(router, http.MethodPost, "/subscriptions/import?format=ndjson", adminKey, body+"\n", key)
+	if code := errorCode(t, reused); reused.Code != http.StatusUnprocessableEntity || code != httputil.CodeIdempotencyKeyReused {
+		t.Errorf("reused: status = %d, code = %q, want %d %q", reused.Code, code, http.StatusUnprocessableEntity, httputil.CodeIdempotencyKeyReused)
+	}
+}
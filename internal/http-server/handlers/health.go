@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthChecker is a storage backend that can tell whether it is usable.
+type HealthChecker interface {
+	Health(ctx context.Context) (storage.Health, error)
+}
+
+
+// Health answers the liveness and readiness probes.
+type Health struct {
+	checker HealthChecker
+	// timeout bounds the storage check of a readiness probe.
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+
+func NewHealth(checker HealthChecker, timeout time.Duration) *Health {
+	return &Health{
+		checker: checker,
+		timeout: timeout,
+	}
+}
+
+
+// Drain makes readiness fail from now on, so load balancers stop sending
+// requests while the server shuts down.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+
+// Liveness godoc
+// @Summary      Liveness probe
+// @Description  Answers as long as the process is up, without checking dependencies.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  responses.HealthResponse
+// @Router       /healthz [get]
+func (h *Health) Liveness() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, responses.HealthResponse{Status: responses.StatusOK})
+	}
+}
+
+
+// Readiness godoc
+// @Summary      Readiness probe
+// @Description  Checks the storage backend and reports the applied migration version. Answers 503 when the storage is unusable or the server is shutting down.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  responses.HealthResponse
+// @Failure      503  {object}  responses.HealthResponse
+// @Router       /readyz [get]
+func (h *Health) Readiness(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.Readiness"
+
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	if h.draining.Load() {
+		ctx.JSON(http.StatusServiceUnavailable, responses.HealthResponse{Status: responses.StatusShutdown})
+
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), h.timeout)
+	defer cancel()
+
+	health, err := h.checker.Health(checkCtx)
+	if err != nil {
+		log.Warn("storage is not ready", sl.Err(err))
+
+		ctx.JSON(http.StatusServiceUnavailable, responses.HealthResponse{
+			Status: responses.StatusUnavailable,
+			Error:  "storage is not ready",
+		})
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.HealthResponse{
+		Status:           responses.StatusOK,
+		MigrationVersion: &health.MigrationVersion,
+	})
+	}
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/apierror"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/validation"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/requests"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/responses"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	// flushEvery is how many exported rows are buffered before they are
+	// flushed to the client.
+	flushEvery = 100
+
+	// maxImportLineSize caps an NDJSON import line. Longer lines are reported
+	// as failed without being held in memory.
+	maxImportLineSize = 1 << 20
+)
+
+// csvHeader names the export columns. price is in minor units of currency.
+var csvHeader = []string{"id", "service_name", "price", "currency", "user_id", "start_date", "end_date"}
+
+// ExportSubscriptions godoc
+// @Summary      Export subscriptions
+// @Description  stream all subscriptions as CSV or newline-delimited JSON
+// @Tags         subscriptions
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        format  query     string  false  "csv (default) or ndjson"
+// @Success      200
+// @Failure      400  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/export [get]
+func (s *Subscription) ExportSubscriptions(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.ExportSubscriptions"
+
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	var write func(models.Subscription) error
+	var flush func() error
+
+	switch format := ctx.DefaultQuery("format", formatCSV); format {
+	case formatCSV:
+		ctx.Header("Content-Type", "text/csv")
+
+		w := csv.NewWriter(ctx.Writer)
+		write = func(subscription models.Subscription) error {
+			var endDate string
+			if subscription.EndDate != nil {
+				endDate = subscription.EndDate.String()
+			}
+
+			return w.Write([]string{
+				strconv.FormatInt(subscription.Id, 10),
+				subscription.ServiceName,
+				strconv.FormatInt(subscription.Price.Amount, 10),
+				subscription.Price.Currency,
+				subscription.UserID,
+				subscription.StartDate.String(),
+				endDate,
+			})
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+
+		if err := w.Write(csvHeader); err != nil {
+			log.Error("failed to write export", sl.Err(err))
+			return
+		}
+	case formatNDJSON:
+		ctx.Header("Content-Type", "application/x-ndjson")
+
+		w := bufio.NewWriter(ctx.Writer)
+		encoder := json.NewEncoder(w)
+		write = func(subscription models.Subscription) error {
+			return encoder.Encode(subscription)
+		}
+		flush = w.Flush
+	default:
+		httputil.Abort(ctx, http.StatusBadRequest, httputil.Error(httputil.CodeInvalidRequest, "unsupported format"))
+
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=subscriptions.%s", ctx.DefaultQuery("format", formatCSV)))
+
+	// A large export takes longer than the server's write timeout allows.
+	if err := http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn("failed to lift write deadline", sl.Err(err))
+	}
+
+	var exported int
+
+	err := s.SubscriptionProvider.Export(ctx.Request.Context(), func(subscription models.Subscription) error {
+		if err := write(subscription); err != nil {
+			return err
+		}
+
+		exported++
+		if exported%flushEvery != 0 {
+			return nil
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	// The status line is already sent, so a failure can only cut the
+	// stream short.
+	if err != nil {
+		log.Error("failed to export subscriptions", sl.Err(err), slog.Int("exported", exported))
+
+		return
+	}
+
+	log.Info("subscriptions exported", slog.Int("exported", exported))
+	}
+}
+
+// ImportSubscriptions godoc
+// @Summary      Import subscriptions
+// @Description  create subscriptions from CSV with a header row or from newline-delimited JSON. CSV prices are in minor units of the currency column. Every line is validated like PUT /subscriptions/{id} and failed lines are reported without stopping the import. A request with an Idempotency-Key already used for another request fails with 422 idempotency_key_reused once the first has been answered, and one sent while the first is still running with 409 idempotency_key_in_use
+// @Tags         subscriptions
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        format  query     string  false  "csv (default) or ndjson"
+// @Param        Idempotency-Key  header  string  false  "makes retries return the first response instead of importing again"
+// @Success      200  {object}  responses.ImportSubscriptionsResponse
+// @Header       200  {string}  Idempotent-Replayed  "true when the response is the one of an earlier request with the Idempotency-Key"
+// @Failure      400  {object}  httputil.Response
+// @Failure      409  {object}  httputil.Response
+// @Failure      422  {object}  httputil.Response
+// @Failure      500  {object}  httputil.Response
+// @Failure      401  {object}  httputil.Response
+// @Failure      403  {object}  httputil.Response
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/import [post]
+func (s *Subscription) ImportSubscriptions(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	const op = "http-server.handlers.ImportSubscriptions"
+
+	log := log.With(
+		slog.String("op", op),
+		requestid.Attr(ctx.Request.Context()),
+	)
+
+	importer := &importer{
+		ctx:      ctx,
+		provider: s.SubscriptionProvider,
+		resp:     responses.ImportSubscriptionsResponse{Errors: []responses.ImportLineError{}},
+	}
+
+	var err error
+
+	switch ctx.DefaultQuery("format", formatCSV) {
+	case formatCSV:
+		err = importer.readCSV(ctx.Request.Body)
+	case formatNDJSON:
+		err = importer.readNDJSON(ctx.Request.Body)
+	default:
+		httputil.Abort(ctx, http.StatusBadRequest, httputil.Error(httputil.CodeInvalidRequest, "unsupported format"))
+
+		return
+	}
+
+	if err == nil {
+		err = importer.flush()
+	}
+
+	if errors.Is(err, errInvalidImport) {
+		log.Warn("failed to read import", sl.Err(err))
+
+		httputil.Abort(ctx, http.StatusBadRequest, httputil.Error(httputil.CodeInvalidRequest, err.Error()))
+
+		return
+	}
+
+	if err != nil {
+		respondError(ctx, log, "failed to import subscriptions", err)
+
+		return
+	}
+
+	// Lines that failed to parse are reported before the ones rejected by
+	// storage, so put them back in file order.
+	sort.SliceStable(importer.resp.Errors, func(a, b int) bool {
+		return importer.resp.Errors[a].Line < importer.resp.Errors[b].Line
+	})
+
+	log.Info("subscriptions imported",
+		slog.Int("imported", importer.resp.Imported),
+		slog.Int("failed", importer.resp.Failed),
+	)
+
+	ctx.JSON(http.StatusOK, importer.resp)
+	}
+}
+
+var errInvalidImport = errors.New("invalid import")
+
+// importer collects parsed lines and creates them through partial batches of
+// up to service.MaxBatchSize, so every line gets Create's checks without a
+// transaction per line.
+type importer struct {
+	ctx      *gin.Context
+	provider Subscriptioner
+	resp     responses.ImportSubscriptionsResponse
+
+	lines      []int
+	operations []models.BatchOperation
+}
+
+func (i *importer) add(line int, request requests.UpdateSubscriptionRequest) error {
+	if errs := validation.Struct(&request); errs != nil {
+		i.fail(line, fmt.Sprintf("%s: %s", errs[0].Field, errs[0].Message))
+		return nil
+	}
+
+	subscription, err := request.Subscription()
+	if err != nil {
+		i.fail(line, apierror.Lookup(err).Message)
+		return nil
+	}
+
+	i.lines = append(i.lines, line)
+	i.operations = append(i.operations, models.BatchOperation{
+		Op:           models.ActionCreate,
+		Subscription: &subscription,
+	})
+
+	if len(i.operations) < service.MaxBatchSize {
+		return nil
+	}
+
+	return i.flush()
+}
+
+func (i *importer) fail(line int, msg string) {
+	i.resp.Failed++
+	i.resp.Errors = append(i.resp.Errors, responses.ImportLineError{Line: line, Error: msg})
+}
+
+func (i *importer) flush() error {
+	if len(i.operations) == 0 {
+		return nil
+	}
+
+	results, err := i.provider.Batch(i.ctx.Request.Context(), i.operations, false)
+	if err != nil {
+		return err
+	}
+
+	for n, result := range results {
+		if result.Err != nil {
+			i.fail(i.lines[n], batchErrorMessage(result.Err))
+			continue
+		}
+		i.resp.Imported++
+	}
+
+	i.lines, i.operations = i.lines[:0], i.operations[:0]
+
+	return nil
+}
+
+func (i *importer) readCSV(body io.Reader) error {
+	r := csv.NewReader(body)
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("%w: missing csv header", errInvalidImport)
+	}
+
+	columns := make(map[string]int, len(header))
+	for n, name := range header {
+		columns[name] = n
+	}
+
+	for _, name := range []string{"service_name", "price", "currency", "user_id", "start_date"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("%w: missing csv column %s", errInvalidImport, name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		n, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return record[n]
+	}
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			i.fail(parseErr.Line, parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := r.FieldPos(0)
+
+		price, err := strconv.ParseInt(field(record, "price"), 10, 64)
+		if err != nil {
+			i.fail(line, "invalid price")
+			continue
+		}
+
+		err = i.add(line, requests.UpdateSubscriptionRequest{
+			ServiceName: field(record, "service_name"),
+			Price:       models.Money{Amount: price, Currency: field(record, "currency")},
+			UserID:      field(record, "user_id"),
+			StartDate:   field(record, "start_date"),
+			EndDate:     field(record, "end_date"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (i *importer) readNDJSON(body io.Reader) error {
+	reader := bufio.NewReader(body)
+
+	for line := 1; ; line++ {
+		data, tooLong, err := readLine(reader, maxImportLineSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		switch {
+		case tooLong:
+			i.fail(line, fmt.Sprintf("line is longer than %d bytes", maxImportLineSize))
+		case len(data) > 0:
+			var subscription requests.UpdateSubscriptionRequest
+			if jsonErr := json.Unmarshal(data, &subscription); jsonErr != nil {
+				i.fail(line, "invalid json")
+				break
+			}
+
+			if addErr := i.add(line, subscription); addErr != nil {
+				return addErr
+			}
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// readLine reads the next line of r without its line ending. A line longer
+// than limit is skipped to its end and reported with tooLong instead. err is
+// io.EOF for the last line.
+func readLine(r *bufio.Reader, limit int) ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+
+	for {
+		chunk, err := r.ReadSlice('\n')
+
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(bytes.TrimRight(line, "\r\n")) > limit {
+				line, tooLong = nil, true
+			}
+		}
+
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return bytes.TrimRight(line, "\r\n"), tooLong, err
+		}
+	}
+}
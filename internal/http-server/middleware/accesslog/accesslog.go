@@ -0,0 +1,54 @@
+package accesslog
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// quietRoutes are polled by probes and Prometheus and are only logged at debug
+// level, so they don't drown out real traffic.
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+
+// New logs one line per request once it is handled: at error level for 5xx,
+// warn for 4xx and info otherwise. The query string is left out, as it may
+// carry personal data such as user IDs.
+func New(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case quietRoutes[ctx.FullPath()]:
+			level = slog.LevelDebug
+		}
+
+		log.LogAttrs(ctx.Request.Context(), level, "request handled",
+			requestid.Attr(ctx.Request.Context()),
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", ctx.Writer.Size()),
+			slog.String("remote_addr", ctx.ClientIP()),
+			slog.String("user_agent", ctx.Request.UserAgent()),
+		)
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/authn"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"github.com/gin-gonic/gin"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+
+// Middleware checks the X-API-Key header, or else the bearer token in the
+// Authorization header, with authenticator. It rejects requests without valid
+// credentials with 401 and makes the others act as their principal, see
+// service.WithPrincipal.
+func Middleware(log *slog.Logger, authenticator *authn.Authenticator) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, err := authenticator.Authenticate(ctx.GetHeader(HeaderAPIKey), ctx.GetHeader("Authorization"))
+		if err != nil {
+			log.Warn("unauthenticated request",
+				requestid.Attr(ctx.Request.Context()),
+				slog.String("path", ctx.FullPath()),
+				sl.Err(err),
+			)
+
+			ctx.Header("WWW-Authenticate", `Bearer realm="data-aggregation"`)
+			httputil.Abort(ctx, http.StatusUnauthorized, httputil.Error(httputil.CodeUnauthorized, "unauthorized"))
+
+			return
+		}
+
+		ctx.Request = ctx.Request.WithContext(service.WithPrincipal(ctx.Request.Context(), principal))
+		ctx.Next()
+	}
+}
+
+
+// RequireRole lets through only principals with role. It must run after
+// Middleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if FromContext(ctx).Role != role {
+			httputil.Abort(ctx, http.StatusForbidden, httputil.Error(httputil.CodeForbidden, "forbidden"))
+
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+
+// FromContext returns the principal stored by Middleware. Requests that didn't
+// go through it get the zero Principal, which has no access to anything.
+func FromContext(ctx *gin.Context) models.Principal {
+	return service.PrincipalFrom(ctx.Request.Context())
+}
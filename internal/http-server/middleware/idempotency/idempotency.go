@@ -0,0 +1,284 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/config"
+	"github.com/BahadirAhmedov/data-aggregation/internal/http-server/middleware/auth"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/apierror"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed is set on responses replayed from an earlier request.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// replayedHeaders are the response headers kept for a replay. The others,
+// such as X-Request-ID, belong to the request being answered.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+
+// Store keeps the requests sent with an Idempotency-Key, each under the key
+// and the subject of the caller that sent it.
+type Store interface {
+	// ReserveIdempotencyKey claims the key of request until it expires. When
+	// the key is held, it returns the request holding it and false instead.
+	ReserveIdempotencyKey(ctx context.Context, request storage.IdempotentRequest) (storage.IdempotentRequest, bool, error)
+	// CompleteIdempotencyKey stores the hash and response of the request
+	// holding the key.
+	CompleteIdempotencyKey(ctx context.Context, request storage.IdempotentRequest) error
+	// ReleaseIdempotencyKey frees a key that has no response yet.
+	ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error
+}
+
+
+// New makes requests with an Idempotency-Key header safe to retry for ttl. The
+// first request with a key runs and its response is stored; a retry with the
+// same method, URL and body gets that response again, with
+// Idempotent-Replayed set, a retry with anything else 422 and one sent while
+// the first is still running 409. Server errors aren't stored, so the retry
+// of a failed request runs again. Requests without the header pass through.
+// It must run after auth.Middleware.
+//
+// The body of a keyed request is hashed as the handler reads it, so imports of
+// any size can carry a key. A retry's body is only read once the first
+// attempt has been answered.
+func New(log *slog.Logger, store Store, cfg config.Idempotency) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+	key := ctx.GetHeader(HeaderKey)
+	if key == "" {
+		ctx.Next()
+
+		return
+	}
+
+	log := log.With(
+		requestid.Attr(ctx.Request.Context()),
+		slog.String("idempotency_key", key),
+	)
+
+	if !validKey(key) {
+		log.Warn("invalid idempotency key")
+
+		httputil.Abort(ctx, http.StatusBadRequest, httputil.Error(httputil.CodeInvalidRequest, "Idempotency-Key must be 1 to 255 visible ASCII characters"))
+
+		return
+	}
+
+	request := storage.IdempotentRequest{
+		Scope:     auth.FromContext(ctx).Subject,
+		Key:       key,
+		ExpiresAt: time.Now().Add(cfg.TTL),
+	}
+
+	held, reserved, err := store.ReserveIdempotencyKey(ctx.Request.Context(), request)
+	if err != nil {
+		respondError(ctx, log, "failed to reserve idempotency key", err)
+
+		return
+	}
+
+	body := newHashingBody(ctx.Request)
+	ctx.Request.Body = body
+
+	if !reserved {
+		replay(ctx, log, body, held)
+
+		return
+	}
+
+	recorder := &recorder{ResponseWriter: ctx.Writer}
+	ctx.Writer = recorder
+
+	// The key outlives the request: a client that gave up still retries, and
+	// must get the response it missed.
+	storeCtx := context.WithoutCancel(ctx.Request.Context())
+
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+
+		// Also reached when a handler panics.
+		if err := store.ReleaseIdempotencyKey(storeCtx, request.Scope, request.Key); err != nil {
+			log.Error("failed to release idempotency key", sl.Err(err))
+		}
+	}()
+
+	ctx.Next()
+
+	// Nothing was answered when the client left before the handler wrote, so
+	// a retry must run the request.
+	status := recorder.Status()
+	if !recorder.Written() || status >= http.StatusInternalServerError {
+		return
+	}
+
+	// What the handler left unread still tells this request from others.
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		log.Warn("failed to read the rest of the request body", sl.Err(err))
+
+		return
+	}
+
+	request.Hash = body.Sum()
+	request.Status = status
+	request.Body = recorder.body.Bytes()
+	request.Header = make(map[string]string)
+	for _, name := range replayedHeaders {
+		if value := recorder.Header().Get(name); value != "" {
+			request.Header[name] = value
+		}
+	}
+
+	if err := store.CompleteIdempotencyKey(storeCtx, request); err != nil {
+		// The response is already sent; a retry will run the request again.
+		log.Error("failed to store idempotent response", sl.Err(err))
+
+		return
+	}
+
+	completed = true
+	}
+}
+
+
+// replay answers a request whose key is held by held. The hash of the first
+// attempt is only known once it has been answered, so until then any request
+// with the key gets 409.
+func replay(ctx *gin.Context, log *slog.Logger, body *hashingBody, held storage.IdempotentRequest) {
+	if held.Status == 0 {
+		log.Warn("idempotency key in use")
+
+		httputil.Abort(ctx, http.StatusConflict, httputil.Error(httputil.CodeIdempotencyKeyInUse, "a request with this Idempotency-Key is still in progress"))
+
+		return
+	}
+
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		log.Warn("failed to read request body", sl.Err(err))
+
+		httputil.Abort(ctx, http.StatusBadRequest, httputil.Error(httputil.CodeInvalidRequest, "invalid request"))
+
+		return
+	}
+
+	switch {
+	case held.Hash != body.Sum():
+		log.Warn("idempotency key reused for a different request")
+
+		httputil.Abort(ctx, http.StatusUnprocessableEntity, httputil.Error(httputil.CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request"))
+	default:
+		log.Info("idempotent request replayed", slog.Int("status", held.Status))
+
+		for name, value := range held.Header {
+			ctx.Header(name, value)
+		}
+		ctx.Header(HeaderReplayed, "true")
+
+		ctx.Status(held.Status)
+		if _, err := ctx.Writer.Write(held.Body); err != nil {
+			log.Warn("failed to write replayed response", sl.Err(err))
+		}
+
+		ctx.Abort()
+	}
+}
+
+
+func respondError(ctx *gin.Context, log *slog.Logger, msg string, err error) {
+	if errors.Is(err, context.Canceled) {
+		log.Warn("request cancelled by client", sl.Err(err))
+
+		ctx.Abort()
+
+		return
+	}
+
+	log.Error(msg, sl.Err(err))
+
+	apiErr := apierror.Lookup(err)
+	httputil.Abort(ctx, apiErr.Status, httputil.Error(apiErr.Code, apiErr.Message))
+}
+
+
+// hashingBody hashes a request body as it is read. The hash identifies the
+// request by its method, URL and body, so a key sent to another endpoint
+// doesn't replay the wrong response.
+type hashingBody struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+
+func newHashingBody(r *http.Request) *hashingBody {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+
+	return &hashingBody{ReadCloser: r.Body, hash: h}
+}
+
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	return n, err
+}
+
+
+// Sum is the hash of what was read so far.
+func (b *hashingBody) Sum() string {
+	return hex.EncodeToString(b.hash.Sum(nil))
+}
+
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+
+	for i := range len(key) {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+
+// recorder keeps a copy of the response body while writing it.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+
+func (r *recorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
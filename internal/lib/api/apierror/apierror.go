@@ -0,0 +1,71 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/money"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+)
+
+// Error is how an error is reported to a client. Status is the HTTP status;
+// other transports derive their own status from it, so every API reports an
+// error the same way.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+
+// known gives every error the service may return exactly one status, so a
+// caller's own mistakes are always 4xx and only failures worth retrying are
+// 5xx.
+var known = []struct {
+	err error
+	Error
+}{
+	{storage.ErrSubscriptionNotFound, Error{http.StatusNotFound, httputil.CodeNotFound, "subscription not found"}},
+	{storage.ErrSubscriptionExists, Error{http.StatusConflict, httputil.CodeConflict, "subscription already exists"}},
+	{storage.ErrVersionMismatch, Error{http.StatusPreconditionFailed, httputil.CodePreconditionFailed, "subscription was changed since it was read"}},
+	{service.ErrVersionRequired, Error{http.StatusPreconditionFailed, httputil.CodePreconditionFailed, "version of the subscription is required, or force to overwrite any version"}},
+	{service.ErrForbidden, Error{http.StatusForbidden, httputil.CodeForbidden, "forbidden"}},
+	{service.ErrInvalidSubscription, Error{http.StatusUnprocessableEntity, "invalid_subscription", "invalid subscription"}},
+	{service.ErrInvalidStartDateFormat, Error{http.StatusUnprocessableEntity, "invalid_start_date", "invalid start_date format"}},
+	{service.ErrInvalidEndDateFormat, Error{http.StatusUnprocessableEntity, "invalid_end_date", "invalid end_date format"}},
+	{service.ErrEndDateBeforeStartDate, Error{http.StatusUnprocessableEntity, "end_date_before_start_date", "end_date is before start_date"}},
+	{service.ErrEmptyPatch, Error{http.StatusUnprocessableEntity, "empty_patch", "no fields to update"}},
+	{service.ErrInvalidGroupBy, Error{http.StatusUnprocessableEntity, "invalid_group_by", "invalid group_by field"}},
+	{service.ErrInvalidSortField, Error{http.StatusUnprocessableEntity, "invalid_sort", "invalid sort field"}},
+	{service.ErrInvalidFilter, Error{http.StatusUnprocessableEntity, "invalid_filter", "invalid filter"}},
+	{service.ErrInvalidBatchOperation, Error{http.StatusUnprocessableEntity, "invalid_batch_operation", "invalid batch operation"}},
+	{service.ErrInvalidBatchSize, Error{http.StatusUnprocessableEntity, httputil.CodeValidationFailed, fmt.Sprintf("batch must contain between 1 and %d operations", service.MaxBatchSize)}},
+	{storage.ErrBatchAborted, Error{http.StatusUnprocessableEntity, "batch_aborted", "batch rolled back"}},
+	{money.ErrUnknownRate, Error{http.StatusUnprocessableEntity, "unknown_exchange_rate", "no exchange rate for the currency"}},
+	{context.DeadlineExceeded, Error{http.StatusGatewayTimeout, httputil.CodeTimeout, "request timed out"}},
+}
+
+var Internal = Error{http.StatusInternalServerError, httputil.CodeInternal, "internal server error"}
+
+
+// Lookup returns the Error for err, which is Internal for anything not known.
+// A missing exchange rate is reported with the currency it is missing for.
+func Lookup(err error) Error {
+	for _, known := range known {
+		if errors.Is(err, known.err) {
+			apiErr := known.Error
+
+			var rateErr *money.UnknownRateError
+			if errors.As(err, &rateErr) {
+				apiErr.Message = rateErr.Error()
+			}
+
+			return apiErr
+		}
+	}
+	return Internal
+}
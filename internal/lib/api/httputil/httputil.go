@@ -1,16 +1,124 @@
 package httputil
 
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/requestid"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/logger/sl"
+	"github.com/gin-gonic/gin"
+)
+
+// Codes of Response. They are part of the API: clients switch on them, so
+// existing codes must not change.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	// CodePreconditionFailed answers a write whose If-Match no longer matches,
+	// CodePreconditionRequired one without If-Match.
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	// CodeIdempotencyKeyReused answers a request whose Idempotency-Key was
+	// used for a different request, CodeIdempotencyKeyInUse one sent while
+	// the first request with the key is still running.
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse  = "idempotency_key_in_use"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal_error"
+)
 
 
 type Response struct{
+	// Code identifies the kind of error for programs; Error is for people.
+	Code string `json:"code"`
 	Error string `json:"error,omitempty"`	
-	
+	// RequestID is the X-Request-ID of the failed request, for bug reports.
+	RequestID string `json:"request_id,omitempty"`
+	// Errors lists the fields that failed validation.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+
+// FieldError describes why one request field was rejected. Field is the JSON
+// path of the field, e.g. price.amount or operations[2].subscription.user_id,
+// and is empty when the request as a whole couldn't be read.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 
-func Error(msg string) Response {
+func Error(code string, msg string) Response {
 	return Response{
+		Code:  code,
 		Error: msg,
 	}
 }
- 
\ No newline at end of file
+
+
+func ValidationError(errs []FieldError) Response {
+	return Response{
+		Code:   CodeValidationFailed,
+		Error:  "validation failed",
+		Errors: errs,
+	}
+}
+
+
+// Abort writes resp with status, stamped with the request ID, and stops the
+// remaining handlers of the request.
+func Abort(ctx *gin.Context, status int, resp Response) {
+	resp.RequestID = requestid.Get(ctx.Request.Context())
+	ctx.AbortWithStatusJSON(status, resp)
+}
+
+
+// RouteNotFound answers requests to paths the router doesn't know.
+func RouteNotFound() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		Abort(ctx, http.StatusNotFound, Error(CodeNotFound, "route not found"))
+	}
+}
+
+
+// MethodNotAllowed answers requests with a method the path doesn't support.
+func MethodNotAllowed() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		Abort(ctx, http.StatusMethodNotAllowed, Error(CodeMethodNotAllowed, "method not allowed"))
+	}
+}
+
+
+// NoReadDeadline lifts the server's read timeout for the rest of the request,
+// for uploads that take longer than it allows.
+func NoReadDeadline(log *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := http.NewResponseController(ctx.Writer).SetReadDeadline(time.Time{}); err != nil {
+			log.Warn("failed to lift read deadline", requestid.Attr(ctx.Request.Context()), sl.Err(err))
+		}
+	}
+}
+
+
+// Recovery turns a panic in a handler into a 500 in the usual error shape.
+func Recovery(log *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered any) {
+		log.Error("handler panicked",
+			requestid.Attr(ctx.Request.Context()),
+			slog.Any("panic", recovered),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("stack", string(debug.Stack())),
+		)
+
+		Abort(ctx, http.StatusInternalServerError, Error(CodeInternal, "internal server error"))
+	})
+}
@@ -0,0 +1,75 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the request ID in both directions. An ID sent by the client
+// is kept, so a request can be traced across services.
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs taken from clients, which end up in logs.
+const maxLength = 128
+
+type ctxKey struct{}
+
+// Middleware gives every request an ID, stores it in the request context and
+// echoes it in the response headers.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestCtx, id := NewContext(ctx.Request.Context(), ctx.GetHeader(Header))
+
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+		ctx.Header(Header, id)
+
+		ctx.Next()
+	}
+}
+
+// NewContext stores id in a copy of ctx, or a generated ID when id is empty or
+// unfit for logs, and returns the ID it stored. Middleware calls it for gin,
+// other front ends call it themselves.
+func NewContext(ctx context.Context, id string) (context.Context, string) {
+	if !valid(id) {
+		id = generate()
+	}
+
+	return context.WithValue(ctx, ctxKey{}, id), id
+}
+
+// Get returns the request ID stored by Middleware, or an empty string.
+func Get(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Attr is the request ID of ctx as a log attribute.
+func Attr(ctx context.Context) slog.Attr {
+	return slog.String("request_id", Get(ctx))
+}
+
+func generate() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// valid accepts non-empty IDs of printable ASCII without spaces.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,109 @@
+package authn
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/config"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoCredentials = errors.New("no credentials")
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrInvalidToken  = errors.New("invalid token")
+)
+
+
+// claims are the JWT claims read on top of the registered ones. An empty role
+// means models.RoleUser.
+type claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+
+// Authenticator checks an API key or an HMAC-signed bearer JWT. It knows
+// nothing of the transport carrying them, so the HTTP and gRPC APIs share it.
+type Authenticator struct {
+	secret  []byte
+	apiKeys []config.APIKey
+}
+
+
+func New(cfg config.Auth) *Authenticator {
+	return &Authenticator{
+		secret:  []byte(cfg.JWTSecret),
+		apiKeys: cfg.APIKeys,
+	}
+}
+
+
+// Authenticate checks apiKey, the value of the X-API-Key header, or else
+// authorization, the value of the Authorization header. Front ends other than
+// gin pass whatever carries the same credentials.
+func (a *Authenticator) Authenticate(apiKey string, authorization string) (models.Principal, error) {
+	if apiKey != "" {
+		return a.apiKey(apiKey)
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return models.Principal{}, ErrNoCredentials
+	}
+
+	return a.token(token)
+}
+
+
+func (a *Authenticator) apiKey(key string) (models.Principal, error) {
+	// Every configured key is compared, so the time taken doesn't tell which
+	// one came closest.
+	var (
+		principal models.Principal
+		found     bool
+	)
+
+	for _, apiKey := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) == 1 {
+			principal = models.Principal{Subject: apiKey.Subject, Role: apiKey.Role}
+			found = true
+		}
+	}
+
+	if !found {
+		return models.Principal{}, ErrInvalidAPIKey
+	}
+
+	return principal, nil
+}
+
+
+func (a *Authenticator) token(token string) (models.Principal, error) {
+	if len(a.secret) == 0 {
+		return models.Principal{}, ErrInvalidToken
+	}
+
+	var c claims
+
+	_, err := jwt.ParseWithClaims(token, &c,
+		func(*jwt.Token) (any, error) { return a.secret, nil },
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return models.Principal{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	if c.Role == "" {
+		c.Role = models.RoleUser
+	}
+
+	if c.Subject == "" || (c.Role != models.RoleUser && c.Role != models.RoleAdmin) {
+		return models.Principal{}, ErrInvalidToken
+	}
+
+	return models.Principal{Subject: c.Subject, Role: c.Role}, nil
+}
@@ -1,10 +1,40 @@
 package sl
 
-import "log/slog"
+import (
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces the value of sensitive attributes.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys, in lower case, whose values never reach
+// the logs.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"x-api-key":     true,
+	"api_key":       true,
+	"apikey":        true,
+	"password":      true,
+	"token":         true,
+	"secret":        true,
+	"jwt-secret":    true,
+}
 
 func Err(err error) slog.Attr {
 	return slog.Attr{
 		Key: "error",
 		Value: slog.StringValue(err.Error()),
 	}
-}
\ No newline at end of file
+}
+
+// Redact is a slog.HandlerOptions.ReplaceAttr that hides the values of
+// credentials, whatever group they are logged in.
+func Redact(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
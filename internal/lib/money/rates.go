@@ -0,0 +1,135 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+)
+
+var ErrUnknownRate = errors.New("no exchange rate")
+
+
+// UnknownRateError is the ErrUnknownRate of a currency, which it names so
+// clients can tell which one.
+type UnknownRateError struct {
+	Currency string
+}
+
+
+func (e *UnknownRateError) Error() string {
+	return fmt.Sprintf("%s for %s", ErrUnknownRate, e.Currency)
+}
+
+
+func (e *UnknownRateError) Is(target error) bool {
+	return target == ErrUnknownRate
+}
+
+
+// Rates converts between currencies. Rate returns how many units of to one
+// unit of from is worth, both in major units.
+type Rates interface {
+	Rate(from string, to string) (*big.Rat, error)
+}
+
+
+// Table holds fixed exchange rates of several currencies against one base
+// currency.
+type Table struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+
+// NewTable builds a Table from rates given as decimal strings, where "92.5"
+// for USD against a RUB base means 1 USD is worth 92.5 RUB.
+func NewTable(base string, rates map[string]string) (*Table, error) {
+	const op = "lib.money.NewTable"
+
+	if !models.IsCurrencyCode(base) {
+		return nil, fmt.Errorf("%s: invalid base currency %q", op, base)
+	}
+
+	table := &Table{
+		base:  base,
+		rates: map[string]*big.Rat{base: big.NewRat(1, 1)},
+	}
+
+	for currency, value := range rates {
+		if !models.IsCurrencyCode(currency) {
+			return nil, fmt.Errorf("%s: invalid currency %q", op, currency)
+		}
+
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("%s: invalid rate %q for %s", op, value, currency)
+		}
+
+		table.rates[currency] = rate
+	}
+
+	return table, nil
+}
+
+
+func (t *Table) Rate(from string, to string) (*big.Rat, error) {
+	fromRate, ok := t.rates[from]
+	if !ok {
+		return nil, &UnknownRateError{Currency: from}
+	}
+
+	toRate, ok := t.rates[to]
+	if !ok {
+		return nil, &UnknownRateError{Currency: to}
+	}
+
+	return new(big.Rat).Quo(fromRate, toRate), nil
+}
+
+
+// Convert adds up amounts in currency. The exact total is rounded to the minor
+// unit of currency only once, half away from zero.
+func Convert(rates Rates, amounts []models.Money, currency string) (models.Money, error) {
+	total := new(big.Rat)
+
+	for _, amount := range amounts {
+		rate, err := rates.Rate(amount.Currency, currency)
+		if err != nil {
+			return models.Money{}, err
+		}
+
+		// amount is in minor units of its own currency, so bring it to major
+		// units, convert, and scale to the minor units of currency.
+		value := new(big.Rat).SetInt64(amount.Amount)
+		value.Mul(value, rate)
+		value.Mul(value, new(big.Rat).SetFrac(pow10(models.MinorUnits(currency)), pow10(models.MinorUnits(amount.Currency))))
+
+		total.Add(total, value)
+	}
+
+	return models.Money{Amount: round(total), Currency: currency}, nil
+}
+
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+
+// round rounds r to the nearest integer, halves away from zero.
+func round(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	quo, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+
+	if rem.Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+
+	if r.Sign() < 0 {
+		quo.Neg(quo)
+	}
+
+	return quo.Int64()
+}
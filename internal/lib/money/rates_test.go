@@ -0,0 +1,109 @@
+package money
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+)
+
+func TestConvert(t *testing.T) {
+	rates, err := NewTable("RUB", map[string]string{
+		"USD": "92.5",
+		"JPY": "0.6",
+		"KWD": "300",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	money := func(amount int64, currency string) models.Money {
+		return models.Money{Amount: amount, Currency: currency}
+	}
+
+	tests := []struct {
+		name     string
+		amounts  []models.Money
+		currency string
+		want     models.Money
+	}{
+		{name: "nothing", currency: "RUB", want: money(0, "RUB")},
+		{name: "same currency", amounts: []models.Money{money(49900, "RUB")}, currency: "RUB", want: money(49900, "RUB")},
+		{name: "into the base", amounts: []models.Money{money(1299, "USD")}, currency: "RUB", want: money(120158, "RUB")},
+		{name: "out of the base", amounts: []models.Money{money(10000, "RUB")}, currency: "USD", want: money(108, "USD")},
+		{name: "half rounds away from zero", amounts: []models.Money{money(1, "USD")}, currency: "RUB", want: money(93, "RUB")},
+		{name: "total rounded once", amounts: []models.Money{money(1, "USD"), money(1, "USD")}, currency: "RUB", want: money(185, "RUB")},
+		{name: "mixed currencies", amounts: []models.Money{money(10000, "RUB"), money(100, "USD")}, currency: "RUB", want: money(19250, "RUB")},
+		{name: "from no minor unit", amounts: []models.Money{money(1000, "JPY")}, currency: "RUB", want: money(60000, "RUB")},
+		{name: "into no minor unit", amounts: []models.Money{money(10000, "RUB")}, currency: "JPY", want: money(167, "JPY")},
+		{name: "from three digits", amounts: []models.Money{money(1000, "KWD")}, currency: "RUB", want: money(30000, "RUB")},
+		{name: "between non-base currencies", amounts: []models.Money{money(100, "USD")}, currency: "JPY", want: money(154, "JPY")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(rates, tt.amounts, tt.currency)
+			if err != nil {
+				t.Fatalf("Convert error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+
+func TestConvertUnknownRate(t *testing.T) {
+	rates, err := NewTable("RUB", map[string]string{"USD": "92.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		amounts  []models.Money
+		currency string
+		missing  string
+	}{
+		{name: "unknown target", amounts: []models.Money{{Amount: 100, Currency: "RUB"}}, currency: "EUR", missing: "EUR"},
+		{name: "unknown source", amounts: []models.Money{{Amount: 100, Currency: "GBP"}}, currency: "RUB", missing: "GBP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Convert(rates, tt.amounts, tt.currency)
+			if !errors.Is(err, ErrUnknownRate) {
+				t.Fatalf("Convert error = %v, want ErrUnknownRate", err)
+			}
+
+			var rateErr *UnknownRateError
+			if !errors.As(err, &rateErr) || rateErr.Currency != tt.missing {
+				t.Errorf("Convert error = %v, want one naming %s", err, tt.missing)
+			}
+		})
+	}
+}
+
+
+func TestNewTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		rates map[string]string
+	}{
+		{name: "invalid base", base: "rub"},
+		{name: "invalid currency", base: "RUB", rates: map[string]string{"US": "92.5"}},
+		{name: "not a number", base: "RUB", rates: map[string]string{"USD": "abc"}},
+		{name: "zero rate", base: "RUB", rates: map[string]string{"USD": "0"}},
+		{name: "negative rate", base: "RUB", rates: map[string]string{"USD": "-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTable(tt.base, tt.rates); err == nil {
+				t.Error("NewTable error = nil, want one")
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// dateLayout is the MM-YYYY format of subscription dates.
+	dateLayout = "01-2006"
+
+	// TagMonthYear accepts an MM-YYYY date.
+	TagMonthYear = "month_year"
+	// TagMonthYearOrEmpty is TagMonthYear that also accepts an empty string,
+	// for optional pointer fields where empty means clearing the value.
+	TagMonthYearOrEmpty = "month_year_or_empty"
+	// TagMonthYearGTE accepts an MM-YYYY date that isn't before the MM-YYYY
+	// date in the sibling field named by its parameter, e.g.
+	// month_year_gte=StartDate.
+	TagMonthYearGTE = "month_year_gte"
+)
+
+
+// Register teaches gin's validator the custom tags of this package and makes
+// it report fields by their JSON or query name. Call it once before serving.
+func Register() error {
+	const op = "lib.validation.Register"
+
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("%s: unexpected validator engine %T", op, binding.Validator.Engine())
+	}
+
+	v.RegisterTagNameFunc(fieldName)
+
+	if err := v.RegisterValidation(TagMonthYear, monthYear); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := v.RegisterValidation(TagMonthYearOrEmpty, monthYearOrEmpty); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := v.RegisterValidation(TagMonthYearGTE, monthYearGTE); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+
+// Struct validates v like request binding does. It is for values that are
+// decoded some other way, like the lines of an import.
+func Struct(v any) []httputil.FieldError {
+	if err := binding.Validator.ValidateStruct(v); err != nil {
+		return Errors(err)
+	}
+	return nil
+}
+
+
+// Errors turns an error from request binding into field errors.
+func Errors(err error) []httputil.FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		errs := make([]httputil.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			code := fe.Tag()
+			if code == TagMonthYearOrEmpty {
+				code = TagMonthYear
+			}
+
+			errs = append(errs, httputil.FieldError{
+				Field:   fieldPath(fe),
+				Code:    code,
+				Message: message(fe),
+			})
+		}
+		return errs
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []httputil.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: "must be " + typeName(typeErr.Type),
+		}}
+	}
+
+	if errors.Is(err, io.EOF) {
+		return []httputil.FieldError{{Code: "required", Message: "request body is empty"}}
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return []httputil.FieldError{{Code: "malformed", Message: "request body is not valid JSON"}}
+	}
+
+	return []httputil.FieldError{{Code: "invalid", Message: "request could not be read"}}
+}
+
+
+// Failed reports whether err comes from a request that was read but broke
+// validation rules, as opposed to one that couldn't be read at all.
+func Failed(err error) bool {
+	var validationErrs validator.ValidationErrors
+	return errors.As(err, &validationErrs)
+}
+
+
+// fieldName names struct fields after their json tag, or their form tag for
+// query parameters, so errors point at what the client actually sent.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+
+// fieldPath drops the request type from the namespace of fe, leaving the path
+// of the field inside the body.
+func fieldPath(fe validator.FieldError) string {
+	_, path, _ := strings.Cut(fe.Namespace(), ".")
+	return path
+}
+
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "uuid", "uuid4":
+		return "must be a UUID"
+	case TagMonthYear, TagMonthYearOrEmpty:
+		return "must be a date in MM-YYYY format"
+	case TagMonthYearGTE:
+		return "must not be before " + snakeCase(fe.Param())
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "unique":
+		return "must not contain duplicates"
+	case "min", "gte":
+		if fe.Kind() == reflect.String && fe.Param() == "1" {
+			return "must not be empty"
+		}
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	}
+
+	return "failed the " + fe.Tag() + " check"
+}
+
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+
+func monthYear(fl validator.FieldLevel) bool {
+	_, err := time.Parse(dateLayout, fl.Field().String())
+	return err == nil
+}
+
+
+func monthYearOrEmpty(fl validator.FieldLevel) bool {
+	return fl.Field().String() == "" || monthYear(fl)
+}
+
+
+// monthYearGTE passes when either date is missing or malformed, as those are
+// reported by the required and month_year tags of the fields themselves.
+func monthYearGTE(fl validator.FieldLevel) bool {
+	end, err := time.Parse(dateLayout, fl.Field().String())
+	if err != nil {
+		return true
+	}
+
+	other := reflect.Indirect(fl.Parent()).FieldByName(fl.Param())
+	if other.Kind() == reflect.Pointer {
+		if other.IsNil() {
+			return true
+		}
+		other = other.Elem()
+	}
+	if other.Kind() != reflect.String {
+		return true
+	}
+
+	start, err := time.Parse(dateLayout, other.String())
+	if err != nil {
+		return true
+	}
+
+	return !end.Before(start)
+}
+
+
+// snakeCase turns a Go field name like StartDate into start_date.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,188 @@
+package validation_test
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/validation"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/requests"
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestMain(m *testing.M) {
+	if err := validation.Register(); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+
+func TestErrorsOfBody(t *testing.T) {
+	const valid = `"service_name":"Yandex Plus","price":{"amount":40000,"currency":"RUB"},"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+
+	tests := []struct {
+		name   string
+		body   string
+		failed bool
+		want   []httputil.FieldError
+	}{
+		{
+			name:   "missing fields",
+			body:   `{"price":{"amount":40000,"currency":"RUB"}}`,
+			failed: true,
+			want: []httputil.FieldError{
+				{Field: "service_name", Code: "required", Message: "is required"},
+				{Field: "user_id", Code: "required", Message: "is required"},
+				{Field: "start_date", Code: "required", Message: "is required"},
+			},
+		},
+		{
+			name:   "nested field",
+			body:   `{` + valid + `,"price":{"amount":-1,"currency":"rub"},"start_date":"07-2025"}`,
+			failed: true,
+			want: []httputil.FieldError{
+				{Field: "price.amount", Code: "gte", Message: "must be at least 0"},
+				{Field: "price.currency", Code: "iso4217", Message: "must be an ISO 4217 currency code"},
+			},
+		},
+		{
+			name:   "malformed values",
+			body:   `{"service_name":"Yandex Plus","price":{"amount":40000,"currency":"RUB"},"user_id":"nope","start_date":"2025-07"}`,
+			failed: true,
+			want: []httputil.FieldError{
+				{Field: "user_id", Code: "uuid", Message: "must be a UUID"},
+				{Field: "start_date", Code: "month_year", Message: "must be a date in MM-YYYY format"},
+			},
+		},
+		{
+			name:   "end before start",
+			body:   `{` + valid + `,"start_date":"07-2025","end_date":"06-2025"}`,
+			failed: true,
+			want: []httputil.FieldError{
+				{Field: "end_date", Code: "month_year_gte", Message: "must not be before start_date"},
+			},
+		},
+		{
+			name: "wrong type",
+			body: `{` + valid + `,"start_date":7}`,
+			want: []httputil.FieldError{
+				{Field: "start_date", Code: "type", Message: "must be a string"},
+			},
+		},
+		{
+			name: "wrong nested type",
+			body: `{"price":{"amount":"400","currency":"RUB"}}`,
+			want: []httputil.FieldError{
+				{Field: "price.amount", Code: "type", Message: "must be an integer"},
+			},
+		},
+		{
+			name: "empty body",
+			body: ``,
+			want: []httputil.FieldError{
+				{Code: "required", Message: "request body is empty"},
+			},
+		},
+		{
+			name: "not JSON",
+			body: `{"service_name":`,
+			want: []httputil.FieldError{
+				{Code: "malformed", Message: "request body is not valid JSON"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request requests.CreateSubscriptionRequest
+
+			err := binding.JSON.BindBody([]byte(tt.body), &request)
+			if err == nil {
+				t.Fatal("BindBody error = nil, want one")
+			}
+
+			if got := validation.Failed(err); got != tt.failed {
+				t.Errorf("Failed = %v, want %v", got, tt.failed)
+			}
+
+			if got := validation.Errors(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Errors =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+
+func TestErrorsOfQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []httputil.FieldError
+	}{
+		{
+			name:  "one of",
+			query: "sort=name",
+			want: []httputil.FieldError{
+				{Field: "sort", Code: "oneof", Message: "must be one of: id, -id, price, -price, start_date, -start_date"},
+			},
+		},
+		{
+			name:  "bounds",
+			query: "limit=1001&min_price=-1",
+			want: []httputil.FieldError{
+				{Field: "min_price", Code: "min", Message: "must be at least 0"},
+				{Field: "limit", Code: "max", Message: "must be at most 1000"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var request requests.ListSubscriptionsRequest
+
+			err = binding.MapFormWithTag(&request, values, "form")
+			if err == nil {
+				err = binding.Validator.ValidateStruct(&request)
+			}
+			if err == nil {
+				t.Fatal("binding error = nil, want one")
+			}
+
+			if got := validation.Errors(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Errors =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+
+func TestStruct(t *testing.T) {
+	valid := requests.CreateSubscriptionRequest{
+		ServiceName: "Yandex Plus",
+		UserID:      "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		StartDate:   "07-2025",
+	}
+	valid.Price.Amount = 40000
+	valid.Price.Currency = "RUB"
+
+	if errs := validation.Struct(valid); errs != nil {
+		t.Errorf("Struct of a valid request = %+v, want nil", errs)
+	}
+
+	invalid := valid
+	invalid.EndDate = "13-2025"
+
+	want := []httputil.FieldError{{Field: "end_date", Code: "month_year", Message: "must be a date in MM-YYYY format"}}
+	if errs := validation.Struct(invalid); !reflect.DeepEqual(errs, want) {
+		t.Errorf("Struct = %+v, want %+v", errs, want)
+	}
+}
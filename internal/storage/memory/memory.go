@@ -0,0 +1,688 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+)
+
+// Storage keeps subscriptions in process memory. It implements the same
+// contract as postgre.Storage, including the uniqueness of
+// (user_id, service_name, start_date), so it can stand in for Postgres in
+// tests and local runs. Data is lost when the process exits.
+type Storage struct {
+	mu            sync.RWMutex
+	lastID        int64
+	subscriptions map[int64]models.Subscription
+	history       []models.SubscriptionChange
+	idempotent    map[idempotencyKey]storage.IdempotentRequest
+}
+
+// idempotencyKey identifies an IdempotentRequest.
+type idempotencyKey struct {
+	scope string
+	key   string
+}
+
+func New() *Storage {
+	return &Storage{
+		subscriptions: make(map[int64]models.Subscription),
+		idempotent:    make(map[idempotencyKey]storage.IdempotentRequest),
+	}
+}
+
+// Close is a no-op; it lets Storage be shut down like postgre.Storage.
+func (s *Storage) Close() error {
+	return nil
+}
+
+// Health always succeeds, as there is nothing to connect to.
+func (s *Storage) Health(_ context.Context) (storage.Health, error) {
+	return storage.Health{}, nil
+}
+
+func (s *Storage) Create(_ context.Context, subscription models.Subscription) (int64, error) {
+	const op = "storage.memory.Create"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.create(subscription)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Read(_ context.Context, id int64) (models.Subscription, error) {
+	const op = "storage.memory.Read"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.DeletedAt != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+	}
+
+	return clone(sub), nil
+}
+
+// Owner returns the user_id of the subscription, whether it is deleted or not.
+func (s *Storage) Owner(_ context.Context, id int64) (string, error) {
+	const op = "storage.memory.Owner"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+	}
+
+	return sub.UserID, nil
+}
+
+// List returns one page of subscriptions matching filter along with the total
+// number of matching subscriptions, which is only counted, and otherwise 0,
+// when filter.CountTotal is set.
+func (s *Storage) List(_ context.Context, filter models.ListFilter) ([]models.Subscription, int64, error) {
+	var compare func(a, b models.ListCursor) int
+	switch filter.SortBy {
+	case models.SortByPrice:
+		compare = func(a, b models.ListCursor) int { return cmp.Compare(a.Price, b.Price) }
+	case models.SortByStartDate:
+		compare = func(a, b models.ListCursor) int { return a.StartDate.Compare(b.StartDate) }
+	default:
+		compare = func(a, b models.ListCursor) int { return 0 }
+	}
+
+	// less orders by the sort field, then by id, both reversed for Desc.
+	less := func(a, b models.ListCursor) bool {
+		if filter.Desc {
+			a, b = b, a
+		}
+
+		if c := compare(a, b); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []int64
+	for id, sub := range s.subscriptions {
+		if sub.DeletedAt != nil && !filter.IncludeDeleted {
+			continue
+		}
+		if filter.UserID != "" && sub.UserID != filter.UserID {
+			continue
+		}
+		if filter.ServiceName != "" && sub.ServiceName != filter.ServiceName {
+			continue
+		}
+		if filter.Currency != "" && sub.Price.Currency != filter.Currency {
+			continue
+		}
+		if filter.StartFrom != nil && sub.StartDate.Before(*filter.StartFrom) {
+			continue
+		}
+		if filter.StartTo != nil && sub.StartDate.After(*filter.StartTo) {
+			continue
+		}
+		if filter.MinPrice != nil && sub.Price.Amount < *filter.MinPrice {
+			continue
+		}
+		if filter.MaxPrice != nil && sub.Price.Amount > *filter.MaxPrice {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	var total int64
+	if filter.CountTotal {
+		total = int64(len(ids))
+	}
+
+	if filter.After != nil {
+		ids = slices.DeleteFunc(ids, func(id int64) bool {
+			return !less(*filter.After, models.CursorOf(s.subscriptions[id]))
+		})
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return less(models.CursorOf(s.subscriptions[ids[i]]), models.CursorOf(s.subscriptions[ids[j]]))
+	})
+
+	start := min(filter.Offset, len(ids))
+	end := min(start+filter.Limit, len(ids))
+
+	subscriptions := make([]models.Subscription, 0, end-start)
+	for _, id := range ids[start:end] {
+		subscriptions = append(subscriptions, clone(s.subscriptions[id]))
+	}
+
+	return subscriptions, total, nil
+}
+
+// Export calls fn for every subscription in id order. It works on a copy, so
+// fn may be slow without blocking writers.
+func (s *Storage) Export(ctx context.Context, fn func(models.Subscription) error) error {
+	const op = "storage.memory.Export"
+
+	err := s.each(ctx, fn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Sum adds up the cost of the subscriptions matching filter, one total per
+// currency.
+func (s *Storage) Sum(_ context.Context, filter models.CostFilter, detail bool) (models.SumResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := models.SumResult{Totals: []models.Money{}}
+	totals := make(map[string]int64)
+
+	for _, sub := range s.subscriptions {
+		months := billedMonths(sub, filter)
+		if months == 0 {
+			continue
+		}
+
+		cost := models.Money{Amount: sub.Price.Amount * months, Currency: sub.Price.Currency}
+
+		totals[cost.Currency] += cost.Amount
+		result.Count++
+
+		if detail {
+			result.Subscriptions = append(result.Subscriptions, models.SubscriptionCost{
+				Id:          sub.Id,
+				ServiceName: sub.ServiceName,
+				UserID:      sub.UserID,
+				Price:       sub.Price,
+				Months:      months,
+				Cost:        cost,
+			})
+		}
+	}
+
+	for _, currency := range slices.Sorted(maps.Keys(totals)) {
+		result.Totals = append(result.Totals, models.Money{Amount: totals[currency], Currency: currency})
+	}
+
+	slices.SortFunc(result.Subscriptions, func(a, b models.SubscriptionCost) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	return result, nil
+}
+
+// Report groups the cost of the subscriptions matching filter. Every
+// subscription adds its monthly price to the group of each month of the period
+// it is billed in.
+func (s *Storage) Report(_ context.Context, filter models.CostFilter, groupBy []string) ([]models.CostGroup, error) {
+	type groupKey struct {
+		serviceName string
+		userID      string
+		month       models.Month
+		currency    string
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totals := make(map[groupKey]int64)
+	counts := make(map[groupKey]int64)
+
+	for _, sub := range s.subscriptions {
+		if billedMonths(sub, filter) == 0 {
+			continue
+		}
+
+		// Without grouping by month a subscription adds to the same group in
+		// every month, and is only counted once.
+		counted := make(map[groupKey]bool)
+
+		for month := filter.Period.From; !month.After(filter.Period.To); month = month.AddMonths(1) {
+			if !sub.ActiveIn(month) {
+				continue
+			}
+
+			key := groupKey{currency: sub.Price.Currency}
+			for _, field := range groupBy {
+				switch field {
+				case models.GroupByServiceName:
+					key.serviceName = sub.ServiceName
+				case models.GroupByUserID:
+					key.userID = sub.UserID
+				case models.GroupByMonth:
+					key.month = month
+				}
+			}
+
+			totals[key] += sub.Price.Amount
+			if !counted[key] {
+				counted[key] = true
+				counts[key]++
+			}
+		}
+	}
+
+	keys := slices.SortedFunc(maps.Keys(totals), func(a, b groupKey) int {
+		for _, field := range groupBy {
+			var c int
+			switch field {
+			case models.GroupByServiceName:
+				c = cmp.Compare(a.serviceName, b.serviceName)
+			case models.GroupByUserID:
+				c = cmp.Compare(a.userID, b.userID)
+			case models.GroupByMonth:
+				c = a.month.Compare(b.month)
+			}
+
+			if c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.currency, b.currency)
+	})
+
+	groups := make([]models.CostGroup, 0, len(keys))
+	for _, key := range keys {
+		groups = append(groups, models.CostGroup{
+			ServiceName: key.serviceName,
+			UserID:      key.userID,
+			Month:       key.month.String(),
+			Count:       counts[key],
+			Total:       models.Money{Amount: totals[key], Currency: key.currency},
+		})
+	}
+
+	return groups, nil
+}
+
+func (s *Storage) Update(_ context.Context, subscription models.Subscription) (models.Subscription, error) {
+	const op = "storage.memory.Update"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, err := s.update(subscription)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscription, nil
+}
+
+// Patch applies patch to a copy of the subscription under the write lock and
+// stores the copy if check accepts it.
+func (s *Storage) Patch(_ context.Context, id int64, version int64, patch models.Patch, check func(models.Subscription) error) (models.Subscription, error) {
+	const op = "storage.memory.Patch"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.subscriptions[id]
+	if !ok || old.DeletedAt != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+	}
+
+	if version != 0 && old.Version != version {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrVersionMismatch)
+	}
+
+	sub := clone(old)
+	patch.Apply(&sub)
+	if err := check(sub); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sub, err := s.update(sub)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+func (s *Storage) Delete(_ context.Context, id int64, version int64) error {
+	const op = "storage.memory.Delete"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.delete(id, version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Restore brings back a soft-deleted subscription. It fails with
+// storage.ErrSubscriptionExists if an identical subscription was created since.
+func (s *Storage) Restore(_ context.Context, id int64) (models.Subscription, error) {
+	const op = "storage.memory.Restore"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.DeletedAt == nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+	}
+
+	if s.exists(sub) {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionExists)
+	}
+
+	sub.DeletedAt = nil
+	sub.Version++
+
+	s.subscriptions[id] = sub
+	s.record(models.ActionRestore, id, nil, &sub)
+
+	return clone(sub), nil
+}
+
+// History returns every recorded change of the subscription, oldest first.
+func (s *Storage) History(_ context.Context, id int64) ([]models.SubscriptionChange, error) {
+	const op = "storage.memory.History"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var changes []models.SubscriptionChange
+	for _, change := range s.history {
+		if change.SubscriptionId == id {
+			changes = append(changes, change)
+		}
+	}
+
+	if len(changes) == 0 {
+		if _, ok := s.subscriptions[id]; !ok {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+		}
+
+		return []models.SubscriptionChange{}, nil
+	}
+
+	return changes, nil
+}
+
+// Batch runs operations under a single lock. In atomic mode the first failing
+// operation restores the state from before the batch and Batch returns
+// storage.ErrBatchAborted.
+func (s *Storage) Batch(_ context.Context, operations []models.BatchOperation, atomic bool) ([]storage.BatchResult, error) {
+	const op = "storage.memory.Batch"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lastID, snapshot, historyLen := s.lastID, maps.Clone(s.subscriptions), len(s.history)
+
+	results := make([]storage.BatchResult, len(operations))
+	for i, operation := range operations {
+		results[i].Op = operation.Op
+	}
+
+	for i, operation := range operations {
+		var err error
+
+		switch operation.Op {
+		case models.ActionCreate:
+			results[i].ID, err = s.create(*operation.Subscription)
+		case models.ActionUpdate:
+			sub := *operation.Subscription
+			sub.Id, sub.Version = operation.ID, operation.Version
+			_, err = s.update(sub)
+		default:
+			err = s.delete(operation.ID, operation.Version)
+		}
+
+		if err == nil && operation.Op != models.ActionCreate {
+			results[i].ID = operation.ID
+		}
+
+		if err != nil {
+			results[i].Err = err
+
+			if atomic {
+				s.lastID, s.subscriptions, s.history = lastID, snapshot, s.history[:historyLen]
+				return results, fmt.Errorf("%s: %w", op, storage.ErrBatchAborted)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// ReserveIdempotencyKey claims request.Key for request.Scope until
+// request.ExpiresAt. When the key is held and hasn't expired, it returns the
+// request holding it and false instead. Expired keys are dropped on the way.
+func (s *Storage) ReserveIdempotencyKey(_ context.Context, request storage.IdempotentRequest) (storage.IdempotentRequest, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	maps.DeleteFunc(s.idempotent, func(_ idempotencyKey, held storage.IdempotentRequest) bool {
+		return !held.ExpiresAt.After(now)
+	})
+
+	key := idempotencyKey{scope: request.Scope, key: request.Key}
+	if held, ok := s.idempotent[key]; ok {
+		return held, false, nil
+	}
+
+	request.Status, request.Header, request.Body = 0, nil, nil
+	s.idempotent[key] = request
+
+	return request, true, nil
+}
+
+// CompleteIdempotencyKey stores the hash and response of the request holding
+// the key, for retries to replay.
+func (s *Storage) CompleteIdempotencyKey(_ context.Context, request storage.IdempotentRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := idempotencyKey{scope: request.Scope, key: request.Key}
+	if held, ok := s.idempotent[key]; ok {
+		held.Hash, held.Status, held.Header, held.Body = request.Hash, request.Status, maps.Clone(request.Header), slices.Clone(request.Body)
+		s.idempotent[key] = held
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key whose request got no response worth
+// replaying, so a retry runs again.
+func (s *Storage) ReleaseIdempotencyKey(_ context.Context, scope string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := idempotencyKey{scope: scope, key: key}
+	if held, ok := s.idempotent[id]; ok && held.Status == 0 {
+		delete(s.idempotent, id)
+	}
+
+	return nil
+}
+
+// create, update and delete implement the matching Storage methods. The
+// caller must hold s.mu for writing.
+func (s *Storage) create(sub models.Subscription) (int64, error) {
+	sub = clone(sub)
+	sub.Id = s.lastID + 1
+	sub.DeletedAt = nil
+	sub.Version = 1
+
+	if s.exists(sub) {
+		return 0, storage.ErrSubscriptionExists
+	}
+
+	s.lastID = sub.Id
+	s.subscriptions[sub.Id] = sub
+	s.record(models.ActionCreate, sub.Id, nil, &sub)
+
+	return sub.Id, nil
+}
+
+func (s *Storage) update(sub models.Subscription) (models.Subscription, error) {
+	old, ok := s.subscriptions[sub.Id]
+	if !ok || old.DeletedAt != nil {
+		return models.Subscription{}, storage.ErrSubscriptionNotFound
+	}
+
+	if sub.Version != 0 && sub.Version != old.Version {
+		return models.Subscription{}, storage.ErrVersionMismatch
+	}
+
+	sub = clone(sub)
+	sub.DeletedAt = nil
+	sub.Version = old.Version + 1
+
+	if s.exists(sub) {
+		return models.Subscription{}, storage.ErrSubscriptionExists
+	}
+
+	s.subscriptions[sub.Id] = sub
+	s.record(models.ActionUpdate, sub.Id, &old, &sub)
+
+	return clone(sub), nil
+}
+
+func (s *Storage) delete(id int64, version int64) error {
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.DeletedAt != nil {
+		return storage.ErrSubscriptionNotFound
+	}
+
+	if version != 0 && version != sub.Version {
+		return storage.ErrVersionMismatch
+	}
+
+	old := sub
+
+	now := time.Now()
+	sub.DeletedAt = &now
+	sub.Version++
+
+	s.subscriptions[id] = sub
+	s.record(models.ActionDelete, id, &old, nil)
+
+	return nil
+}
+
+// each calls fn for a copy of every subscription that isn't deleted, in id
+// order. The lock is only held while copying.
+func (s *Storage) each(ctx context.Context, fn func(models.Subscription) error) error {
+	s.mu.RLock()
+	subscriptions := make([]models.Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		if sub.DeletedAt == nil {
+			subscriptions = append(subscriptions, clone(sub))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Id < subscriptions[j].Id })
+
+	for _, subscription := range subscriptions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(subscription); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exists reports whether another subscription already has the same user,
+// service and start date as sub. The caller must hold s.mu.
+func (s *Storage) exists(sub models.Subscription) bool {
+	for id, other := range s.subscriptions {
+		if id == sub.Id || other.DeletedAt != nil {
+			continue
+		}
+
+		if other.UserID == sub.UserID &&
+			other.ServiceName == sub.ServiceName &&
+			other.StartDate == sub.StartDate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// billedMonths counts the months of filter.Period sub is billed in, 0 when it
+// is deleted or doesn't match the other fields of filter.
+func billedMonths(sub models.Subscription, filter models.CostFilter) int64 {
+	if sub.DeletedAt != nil ||
+		(filter.UserID != "" && sub.UserID != filter.UserID) ||
+		(filter.ServiceName != "" && sub.ServiceName != filter.ServiceName) {
+		return 0
+	}
+
+	return sub.MonthsIn(filter.Period)
+}
+
+// clone copies sub, so callers can't change what is stored through its
+// pointers.
+func clone(sub models.Subscription) models.Subscription {
+	if sub.EndDate != nil {
+		endDate := *sub.EndDate
+		sub.EndDate = &endDate
+	}
+
+	if sub.DeletedAt != nil {
+		deletedAt := *sub.DeletedAt
+		sub.DeletedAt = &deletedAt
+	}
+
+	return sub
+}
+
+// record appends a change to the history. Snapshots leave out DeletedAt, as
+// the postgre history does. The caller must hold s.mu for writing.
+func (s *Storage) record(action string, id int64, old, new *models.Subscription) {
+	change := models.SubscriptionChange{
+		Id:             int64(len(s.history)) + 1,
+		SubscriptionId: id,
+		Action:         action,
+		ChangedAt:      time.Now(),
+	}
+
+	if old != nil {
+		snapshot := clone(*old)
+		snapshot.DeletedAt = nil
+		change.OldValue = &snapshot
+	}
+
+	if new != nil {
+		snapshot := clone(*new)
+		snapshot.DeletedAt = nil
+		change.NewValue = &snapshot
+	}
+
+	s.history = append(s.history, change)
+}
@@ -0,0 +1,660 @@
+package memory_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
+	"github.com/BahadirAhmedov/data-aggregation/internal/storage/memory"
+)
+
+const (
+	alice = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+	bob   = "7b3e2a10-5c4d-4e8f-9a1b-2c3d4e5f6a7b"
+)
+
+func rub(amount int64) models.Money {
+	return models.Money{Amount: amount, Currency: "RUB"}
+}
+
+func usd(amount int64) models.Money {
+	return models.Money{Amount: amount, Currency: "USD"}
+}
+
+func month(s string) models.Month {
+	m, err := models.ParseMonth(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func period(from, to string) models.Period {
+	return models.Period{From: month(from), To: month(to)}
+}
+
+// subscription builds a subscription that is open-ended when end is empty.
+func subscription(serviceName string, price models.Money, userID string, start string, end string) models.Subscription {
+	sub := models.Subscription{ServiceName: serviceName, Price: price, UserID: userID, StartDate: month(start)}
+	if end != "" {
+		endDate := month(end)
+		sub.EndDate = &endDate
+	}
+	return sub
+}
+
+func create(t *testing.T, s *memory.Storage, sub models.Subscription) int64 {
+	t.Helper()
+
+	id, err := s.Create(t.Context(), sub)
+	if err != nil {
+		t.Fatalf("Create(%+v) error = %v", sub, err)
+	}
+
+	return id
+}
+
+func ids(subs []models.Subscription) []int64 {
+	got := make([]int64, 0, len(subs))
+	for _, sub := range subs {
+		got = append(got, sub.Id)
+	}
+	return got
+}
+
+func TestCreateRead(t *testing.T) {
+	s := memory.New()
+
+	sub := subscription("Yandex Plus", rub(400), alice, "07-2025", "12-2025")
+	id := create(t, s, sub)
+
+	got, err := s.Read(t.Context(), id)
+	if err != nil {
+		t.Fatalf("Read error = %v", err)
+	}
+
+	sub.Id = id
+	if got.Id != sub.Id || got.ServiceName != sub.ServiceName || got.Price != sub.Price || got.StartDate != sub.StartDate || *got.EndDate != *sub.EndDate {
+		t.Errorf("Read = %+v, want %+v", got, sub)
+	}
+
+	if _, err := s.Create(t.Context(), subscription("Yandex Plus", rub(500), alice, "07-2025", "")); !errors.Is(err, storage.ErrSubscriptionExists) {
+		t.Errorf("Create of a duplicate error = %v, want ErrSubscriptionExists", err)
+	}
+
+	if _, err := s.Read(t.Context(), id+1); !errors.Is(err, storage.ErrSubscriptionNotFound) {
+		t.Errorf("Read of a missing id error = %v, want ErrSubscriptionNotFound", err)
+	}
+}
+
+func TestUpdateDelete(t *testing.T) {
+	s := memory.New()
+	id := create(t, s, subscription("Yandex Plus", rub(400), alice, "07-2025", ""))
+	other := create(t, s, subscription("Okko", rub(300), alice, "07-2025", ""))
+
+	update := func(sub models.Subscription, id int64) error {
+		sub.Id = id
+		_, err := s.Update(t.Context(), sub)
+		return err
+	}
+
+	if err := update(subscription("Okko", rub(300), alice, "07-2025", ""), id); !errors.Is(err, storage.ErrSubscriptionExists) {
+		t.Errorf("Update into a duplicate error = %v, want ErrSubscriptionExists", err)
+	}
+
+	if err := update(subscription("Yandex Plus", rub(500), alice, "07-2025", ""), id); err != nil {
+		t.Fatalf("Update error = %v", err)
+	}
+	if got, _ := s.Read(t.Context(), id); got.Price.Amount != 500 {
+		t.Errorf("price after Update = %d, want 500", got.Price.Amount)
+	}
+
+	if err := update(subscription("Ivi", rub(100), alice, "07-2025", ""), other+1); !errors.Is(err, storage.ErrSubscriptionNotFound) {
+		t.Errorf("Update of a missing id error = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	if err := s.Delete(t.Context(), other, 0); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+	if _, err := s.Read(t.Context(), other); !errors.Is(err, storage.ErrSubscriptionNotFound) {
+		t.Errorf("Read after Delete error = %v, want ErrSubscriptionNotFound", err)
+	}
+	if err := s.Delete(t.Context(), other, 0); !errors.Is(err, storage.ErrSubscriptionNotFound) {
+		t.Errorf("second Delete error = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	owner, err := s.Owner(t.Context(), other)
+	if err != nil || owner != alice {
+		t.Errorf("Owner of a deleted subscription = %q, %v, want %q", owner, err, alice)
+	}
+
+	list, _, err := s.List(t.Context(), models.ListFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(list); !slices.Equal(got, []int64{id}) {
+		t.Errorf("List = %v, want only %d", got, id)
+	}
+}
+
+func TestList(t *testing.T) {
+	s := memory.New()
+	yandex := create(t, s, subscription("Yandex Plus", rub(400), alice, "07-2025", ""))
+	okko := create(t, s, subscription("Okko", rub(300), alice, "03-2025", ""))
+	ivi := create(t, s, subscription("Ivi", rub(500), alice, "01-2025", ""))
+	bobs := create(t, s, subscription("Yandex Plus", rub(300), bob, "07-2025", ""))
+	dollars := create(t, s, subscription("Netflix", usd(1299), bob, "05-2025", ""))
+
+	amount := func(p int64) *int64 { return &p }
+	from, to := month("02-2025"), month("06-2025")
+
+	tests := []struct {
+		name   string
+		filter models.ListFilter
+		want   []int64
+		total  int64
+	}{
+		{name: "everything by id", filter: models.ListFilter{Limit: 10}, want: []int64{yandex, okko, ivi, bobs, dollars}, total: 5},
+		{name: "by user", filter: models.ListFilter{UserID: bob, Limit: 10}, want: []int64{bobs, dollars}, total: 2},
+		{name: "by service", filter: models.ListFilter{ServiceName: "Yandex Plus", Limit: 10}, want: []int64{yandex, bobs}, total: 2},
+		{name: "by currency", filter: models.ListFilter{Currency: "USD", Limit: 10}, want: []int64{dollars}, total: 1},
+		{name: "by start date", filter: models.ListFilter{StartFrom: &from, StartTo: &to, Limit: 10}, want: []int64{okko, dollars}, total: 2},
+		{name: "by price", filter: models.ListFilter{Currency: "RUB", MinPrice: amount(350), MaxPrice: amount(450), Limit: 10}, want: []int64{yandex}, total: 1},
+		{name: "price ties by id", filter: models.ListFilter{Currency: "RUB", SortBy: models.SortByPrice, Limit: 10}, want: []int64{okko, bobs, yandex, ivi}, total: 4},
+		{name: "descending start date", filter: models.ListFilter{SortBy: models.SortByStartDate, Desc: true, Limit: 10}, want: []int64{bobs, yandex, dollars, okko, ivi}, total: 5},
+		{name: "second page", filter: models.ListFilter{Limit: 2, Offset: 2}, want: []int64{ivi, bobs}, total: 5},
+		{name: "past the end", filter: models.ListFilter{Limit: 2, Offset: 10}, want: []int64{}, total: 5},
+		{name: "after an id", filter: models.ListFilter{Limit: 2, After: &models.ListCursor{ID: okko}}, want: []int64{ivi, bobs}, total: 5},
+		{name: "after a price tie", filter: models.ListFilter{Currency: "RUB", SortBy: models.SortByPrice, Limit: 10, After: &models.ListCursor{Price: 300, ID: okko}}, want: []int64{bobs, yandex, ivi}, total: 4},
+		{name: "after a descending start date", filter: models.ListFilter{SortBy: models.SortByStartDate, Desc: true, Limit: 10, After: &models.ListCursor{StartDate: month("07-2025"), ID: yandex}}, want: []int64{dollars, okko, ivi}, total: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.CountTotal = true
+
+			list, total, err := s.List(t.Context(), tt.filter)
+			if err != nil {
+				t.Fatalf("List error = %v", err)
+			}
+
+			if got := ids(list); !slices.Equal(got, tt.want) || total != tt.total {
+				t.Errorf("List = %v of %d, want %v of %d", got, total, tt.want, tt.total)
+			}
+		})
+	}
+
+	t.Run("without total", func(t *testing.T) {
+		list, total, err := s.List(t.Context(), models.ListFilter{Limit: 1})
+		if err != nil {
+			t.Fatalf("List error = %v", err)
+		}
+		if got := ids(list); !slices.Equal(got, []int64{yandex}) || total != 0 {
+			t.Errorf("List = %v of %d, want [%d] of 0", got, total, yandex)
+		}
+	})
+}
+
+func TestSum(t *testing.T) {
+	s := memory.New()
+	long := create(t, s, subscription("Yandex Plus", rub(400), alice, "11-2024", ""))
+	short := create(t, s, subscription("Yandex Plus", rub(100), alice, "03-2025", "04-2025"))
+	create(t, s, subscription("Yandex Plus", rub(900), bob, "01-2025", ""))
+	create(t, s, subscription("Okko", usd(1299), alice, "01-2025", ""))
+	deleted := create(t, s, subscription("Ivi", rub(700), alice, "01-2025", ""))
+	if err := s.Delete(t.Context(), deleted, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	alices := func(from, to string) models.CostFilter {
+		return models.CostFilter{Period: period(from, to), UserID: alice, ServiceName: "Yandex Plus"}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.CostFilter
+		totals []models.Money
+		count  int64
+	}{
+		{name: "every month of an open-ended subscription", filter: alices("01-2025", "06-2025"), totals: []models.Money{rub(6*400 + 2*100)}, count: 2},
+		{name: "starting inside the period", filter: alices("09-2024", "12-2024"), totals: []models.Money{rub(2 * 400)}, count: 1},
+		{name: "single month", filter: alices("04-2025", "04-2025"), totals: []models.Money{rub(400 + 100)}, count: 2},
+		{name: "nothing billed", filter: alices("01-2024", "10-2024"), totals: []models.Money{}},
+		{name: "every user", filter: models.CostFilter{Period: period("01-2025", "01-2025"), ServiceName: "Yandex Plus"}, totals: []models.Money{rub(400 + 900)}, count: 2},
+		{name: "every subscription", filter: models.CostFilter{Period: period("01-2025", "01-2025")}, totals: []models.Money{rub(400 + 900), usd(1299)}, count: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Sum(t.Context(), tt.filter, false)
+			if err != nil {
+				t.Fatalf("Sum error = %v", err)
+			}
+			if !slices.Equal(got.Totals, tt.totals) || got.Count != tt.count || got.Subscriptions != nil {
+				t.Errorf("Sum = %+v, want totals %v of %d subscriptions", got, tt.totals, tt.count)
+			}
+		})
+	}
+
+	got, err := s.Sum(t.Context(), alices("04-2025", "06-2025"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.SubscriptionCost{
+		{Id: long, ServiceName: "Yandex Plus", UserID: alice, Price: rub(400), Months: 3, Cost: rub(1200)},
+		{Id: short, ServiceName: "Yandex Plus", UserID: alice, Price: rub(100), Months: 1, Cost: rub(100)},
+	}
+	if !slices.Equal(got.Subscriptions, want) {
+		t.Errorf("Sum breakdown = %+v, want %+v", got.Subscriptions, want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	s := memory.New()
+	create(t, s, subscription("Yandex Plus", rub(400), alice, "12-2024", ""))
+	create(t, s, subscription("Okko", rub(300), alice, "02-2025", "02-2025"))
+	create(t, s, subscription("Okko", usd(1299), bob, "03-2025", ""))
+	create(t, s, subscription("Yandex Plus", rub(100), bob, "02-2025", ""))
+	deleted := create(t, s, subscription("Ivi", rub(900), bob, "01-2025", ""))
+	if err := s.Delete(t.Context(), deleted, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		filter  models.CostFilter
+		groupBy []string
+		want    []models.CostGroup
+	}{
+		{
+			name:   "single total",
+			filter: models.CostFilter{Period: period("01-2025", "03-2025")},
+			want:   []models.CostGroup{{Count: 3, Total: rub(3*400 + 300 + 2*100)}, {Count: 1, Total: usd(1299)}},
+		},
+		{
+			name:    "by service",
+			filter:  models.CostFilter{Period: period("01-2025", "03-2025")},
+			groupBy: []string{models.GroupByServiceName},
+			want: []models.CostGroup{
+				{ServiceName: "Okko", Count: 1, Total: rub(300)},
+				{ServiceName: "Okko", Count: 1, Total: usd(1299)},
+				{ServiceName: "Yandex Plus", Count: 2, Total: rub(3*400 + 2*100)},
+			},
+		},
+		{
+			name:    "by month and user",
+			filter:  models.CostFilter{Period: period("01-2025", "02-2025")},
+			groupBy: []string{models.GroupByMonth, models.GroupByUserID},
+			want: []models.CostGroup{
+				{Month: "01-2025", UserID: alice, Count: 1, Total: rub(400)},
+				{Month: "02-2025", UserID: alice, Count: 2, Total: rub(400 + 300)},
+				{Month: "02-2025", UserID: bob, Count: 1, Total: rub(100)},
+			},
+		},
+		{
+			name:    "filtered",
+			filter:  models.CostFilter{Period: period("01-2025", "03-2025"), UserID: bob},
+			groupBy: []string{models.GroupByServiceName},
+			want:    []models.CostGroup{{ServiceName: "Okko", Count: 1, Total: usd(1299)}, {ServiceName: "Yandex Plus", Count: 1, Total: rub(2 * 100)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Report(t.Context(), tt.filter, tt.groupBy)
+			if err != nil {
+				t.Fatalf("Report error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Report = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	setup := func(t *testing.T) (*memory.Storage, int64) {
+		s := memory.New()
+		return s, create(t, s, subscription("Yandex Plus", rub(400), alice, "07-2025", ""))
+	}
+
+	okko := subscription("Okko", rub(300), alice, "07-2025", "")
+
+	t.Run("atomic rolls back", func(t *testing.T) {
+		s, id := setup(t)
+
+		results, err := s.Batch(t.Context(), []models.BatchOperation{
+			{Op: models.ActionCreate, Subscription: &okko},
+			{Op: models.ActionDelete, ID: id},
+			{Op: models.ActionDelete, ID: id + 10},
+		}, true)
+		if !errors.Is(err, storage.ErrBatchAborted) {
+			t.Fatalf("Batch error = %v, want ErrBatchAborted", err)
+		}
+		if !errors.Is(results[2].Err, storage.ErrSubscriptionNotFound) {
+			t.Errorf("failed operation error = %v, want ErrSubscriptionNotFound", results[2].Err)
+		}
+
+		list, _, _ := s.List(t.Context(), models.ListFilter{Limit: 10})
+		if got := ids(list); !slices.Equal(got, []int64{id}) {
+			t.Errorf("List after a rolled back batch = %v, want only %d", got, id)
+		}
+		if changes, _ := s.History(t.Context(), id); len(changes) != 1 {
+			t.Errorf("History after a rolled back batch has %d entries, want 1", len(changes))
+		}
+
+		// Ids used by the rolled back batch are handed out again.
+		if next := create(t, s, subscription("Ivi", rub(100), alice, "07-2025", "")); next != id+1 {
+			t.Errorf("id after a rolled back batch = %d, want %d", next, id+1)
+		}
+	})
+
+	t.Run("partial keeps what succeeds", func(t *testing.T) {
+		s, id := setup(t)
+		update := subscription("Yandex Plus", rub(500), alice, "07-2025", "")
+
+		results, err := s.Batch(t.Context(), []models.BatchOperation{
+			{Op: models.ActionCreate, Subscription: &okko},
+			{Op: models.ActionCreate, Subscription: &okko},
+			{Op: models.ActionUpdate, ID: id, Subscription: &update},
+			{Op: models.ActionDelete, ID: id + 10},
+		}, false)
+		if err != nil {
+			t.Fatalf("Batch error = %v", err)
+		}
+
+		wantErrs := []error{nil, storage.ErrSubscriptionExists, nil, storage.ErrSubscriptionNotFound}
+		for i, want := range wantErrs {
+			if !errors.Is(results[i].Err, want) {
+				t.Errorf("operation %d error = %v, want %v", i, results[i].Err, want)
+			}
+		}
+
+		if got, _ := s.Read(t.Context(), id); got.Price.Amount != 500 {
+			t.Errorf("price after the batch = %d, want 500", got.Price.Amount)
+		}
+		if _, total, _ := s.List(t.Context(), models.ListFilter{Limit: 10, CountTotal: true}); total != 2 {
+			t.Errorf("%d subscriptions after the batch, want 2", total)
+		}
+	})
+	t.Run("checks versions", func(t *testing.T) {
+		s, id := setup(t)
+		update := subscription("Yandex Plus", rub(500), alice, "07-2025", "")
+
+		results, err := s.Batch(t.Context(), []models.BatchOperation{
+			{Op: models.ActionUpdate, ID: id, Version: 2, Subscription: &update},
+			{Op: models.ActionUpdate, ID: id, Version: 1, Subscription: &update},
+			{Op: models.ActionDelete, ID: id, Version: 1},
+			{Op: models.ActionDelete, ID: id, Version: 2},
+		}, false)
+		if err != nil {
+			t.Fatalf("Batch error = %v", err)
+		}
+
+		wantErrs := []error{storage.ErrVersionMismatch, nil, storage.ErrVersionMismatch, nil}
+		for i, want := range wantErrs {
+			if !errors.Is(results[i].Err, want) {
+				t.Errorf("operation %d error = %v, want %v", i, results[i].Err, want)
+			}
+		}
+	})
+}
+
+func TestExport(t *testing.T) {
+	s := memory.New()
+	for _, service := range []string{"Yandex Plus", "Okko", "Ivi"} {
+		create(t, s, subscription(service, rub(400), alice, "07-2025", ""))
+	}
+	if err := s.Delete(t.Context(), 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int64
+	err := s.Export(t.Context(), func(sub models.Subscription) error {
+		got = append(got, sub.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export error = %v", err)
+	}
+	if !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("Export visited %v, want [1 3]", got)
+	}
+
+	stop := errors.New("stop")
+	err = s.Export(t.Context(), func(models.Subscription) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("Export error = %v, want the one of fn", err)
+	}
+}
+
+func TestRestoreHistory(t *testing.T) {
+	s := memory.New()
+	id := create(t, s, subscription("Yandex Plus", rub(400), alice, "07-2025", ""))
+
+	if _, err := s.Restore(t.Context(), id); !errors.Is(err, storage.ErrSubscriptionNotFound) {
+		t.Errorf("Restore of a live subscription error = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	update := subscription("Yandex Plus", rub(500), alice, "07-2025", "")
+	update.Id = id
+	if _, err := s.Update(t.Context(), update); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(t.Context(), id, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, total, _ := s.List(t.Context(), models.ListFilter{Limit: 10, CountTotal: true}); total != 0 {
+		t.Errorf("List counts %d deleted subscriptions, want 0", total)
+	}
+	list, _, _ := s.List(t.Context(), models.ListFilter{Limit: 10, IncludeDeleted: true})
+	if len(list) != 1 || list[0].DeletedAt == nil {
+		t.Errorf("List with deleted = %+v, want the deleted subscription", list)
+	}
+
+	// A deleted subscription doesn't block a new identical one, which then
+	// blocks restoring the deleted one.
+	again := create(t, s, subscription("Yandex Plus", rub(500), alice, "07-2025", ""))
+	if _, err := s.Restore(t.Context(), id); !errors.Is(err, storage.ErrSubscriptionExists) {
+		t.Errorf("Restore over a duplicate error = %v, want ErrSubscriptionExists", err)
+	}
+	if err := s.Delete(t.Context(), again, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Restore(t.Context(), id); err != nil {
+		t.Fatalf("Restore error = %v", err)
+	}
+	if got, err := s.Read(t.Context(), id); err != nil || got.Price.Amount != 500 {
+		t.Errorf("Read after Restore = %+v, %v, want price 500", got, err)
+	}
+
+	changes, err := s.History(t.Context(), id)
+	if err != nil {
+		t.Fatalf("History error = %v", err)
+	}
+
+	var actions []string
+	for _, change := range changes {
+		actions = append(actions, change.Action)
+	}
+	want := []string{models.ActionCreate, models.ActionUpdate, models.ActionDelete, models.ActionRestore}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("History actions = %v, want %v", actions, want)
+	}
+	if changes[1].OldValue.Price.Amount != 400 || changes[1].NewValue.Price.Amount != 500 {
+		t.Errorf("update entry = %+v -> %+v, want price 400 -> 500", changes[1].OldValue, changes[1].NewValue)
+	}
+	if changes[2].NewValue != nil || changes[3].OldValue != nil {
+		t.Errorf("delete and restore entries = %+v, %+v, want no new value on delete and no old value on restore", changes[2], changes[3])
+	}
+
+	if _, err := s.History(t.Context(), id+10); !errors.Is(err, storage.ErrSubscriptionNotFound) {
+		t.Errorf("History of a missing id error = %v, want ErrSubscriptionNotFound", err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	s := memory.New()
+	id := create(t, s, subscription("Yandex Plus", rub(400), alice, "07-2025", "12-2025"))
+	create(t, s, subscription("Okko", rub(300), alice, "07-2025", ""))
+
+	str := func(s string) *string { return &s }
+	price := func(amount int64) *models.Money {
+		price := rub(amount)
+		return &price
+	}
+	end := func(s string) *models.Month {
+		m := month(s)
+		return &m
+	}
+
+	rejected := errors.New("rejected")
+	check := func(sub models.Subscription) error {
+		if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+			return rejected
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		patch   models.Patch
+		price   int64
+		end     *models.Month
+		wantErr error
+	}{
+		{name: "price only", patch: models.Patch{Price: price(500)}, price: 500, end: end("12-2025")},
+		{name: "end date", patch: models.Patch{EndDate: end("09-2025")}, price: 500, end: end("09-2025")},
+		{name: "clear end date", patch: models.Patch{EndDate: end("10-2025"), ClearEndDate: true}, price: 500},
+		{name: "rejected by check", patch: models.Patch{Price: price(1), EndDate: end("06-2025")}, wantErr: rejected},
+		{name: "into a duplicate", patch: models.Patch{Price: price(1), ServiceName: str("Okko")}, wantErr: storage.ErrSubscriptionExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Patch(t.Context(), id, 0, tt.patch, check)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Patch error = %v, want %v", err, tt.wantErr)
+			}
+
+			read, _ := s.Read(t.Context(), id)
+			if err != nil {
+				if read.Price.Amount != 500 || read.ServiceName != "Yandex Plus" {
+					t.Errorf("Read after a failed Patch = %+v, want it unchanged", read)
+				}
+				return
+			}
+
+			for _, sub := range []models.Subscription{got, read} {
+				if sub.Price.Amount != tt.price || (sub.EndDate == nil) != (tt.end == nil) || (tt.end != nil && *sub.EndDate != *tt.end) {
+					t.Errorf("subscription after Patch = %+v, want price %d and end date %v", sub, tt.price, tt.end)
+				}
+			}
+		})
+	}
+
+	if _, err := s.Patch(t.Context(), id+10, 0, models.Patch{Price: price(1)}, check); !errors.Is(err, storage.ErrSubscriptionNotFound) {
+		t.Errorf("Patch of a missing id error = %v, want ErrSubscriptionNotFound", err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	s := memory.New()
+	id := create(t, s, subscription("Yandex Plus", rub(400), alice, "07-2025", ""))
+
+	if got, _ := s.Read(t.Context(), id); got.Version != 1 {
+		t.Fatalf("version after Create = %d, want 1", got.Version)
+	}
+
+	update := subscription("Yandex Plus", rub(500), alice, "07-2025", "")
+	update.Id, update.Version = id, 2
+	if _, err := s.Update(t.Context(), update); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("Update of a stale version error = %v, want ErrVersionMismatch", err)
+	}
+
+	update.Version = 1
+	updated, err := s.Update(t.Context(), update)
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("Update = %+v, %v, want version 2", updated, err)
+	}
+
+	price := rub(600)
+	if _, err := s.Patch(t.Context(), id, 1, models.Patch{Price: &price}, func(models.Subscription) error { return nil }); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("Patch of a stale version error = %v, want ErrVersionMismatch", err)
+	}
+
+	// Version 0 skips the check.
+	patched, err := s.Patch(t.Context(), id, 0, models.Patch{Price: &price}, func(models.Subscription) error { return nil })
+	if err != nil || patched.Version != 3 {
+		t.Fatalf("Patch = %+v, %v, want version 3", patched, err)
+	}
+
+	if err := s.Delete(t.Context(), id, 2); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("Delete of a stale version error = %v, want ErrVersionMismatch", err)
+	}
+	if err := s.Delete(t.Context(), id, 3); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+
+	restored, err := s.Restore(t.Context(), id)
+	if err != nil || restored.Version != 5 {
+		t.Errorf("Restore = %+v, %v, want version 5", restored, err)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	s := memory.New()
+	request := storage.IdempotentRequest{Scope: alice, Key: "create-1", Hash: "first", ExpiresAt: time.Now().Add(time.Hour)}
+
+	if _, reserved, err := s.ReserveIdempotencyKey(t.Context(), request); err != nil || !reserved {
+		t.Fatalf("first Reserve = %v, %v, want the key", reserved, err)
+	}
+
+	// The same key of another caller is another key.
+	other := request
+	other.Scope = bob
+	if _, reserved, _ := s.ReserveIdempotencyKey(t.Context(), other); !reserved {
+		t.Errorf("Reserve of the key for another scope didn't get it")
+	}
+
+	retry := request
+	retry.Hash = "second"
+	held, reserved, err := s.ReserveIdempotencyKey(t.Context(), retry)
+	if err != nil || reserved || held.Hash != "first" || held.Status != 0 {
+		t.Fatalf("Reserve of a held key = %+v, %v, %v, want the first request still running", held, reserved, err)
+	}
+
+	if err := s.ReleaseIdempotencyKey(t.Context(), alice, "create-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, reserved, _ := s.ReserveIdempotencyKey(t.Context(), request); !reserved {
+		t.Fatalf("Reserve after Release didn't get the key")
+	}
+
+	completed := request
+	completed.Status, completed.Header, completed.Body = 201, map[string]string{"ETag": `"1"`}, []byte(`{"id":1}`)
+	if err := s.CompleteIdempotencyKey(t.Context(), completed); err != nil {
+		t.Fatal(err)
+	}
+
+	// A completed key isn't released, its response is replayed instead.
+	if err := s.ReleaseIdempotencyKey(t.Context(), alice, "create-1"); err != nil {
+		t.Fatal(err)
+	}
+	held, reserved, _ = s.ReserveIdempotencyKey(t.Context(), retry)
+	if reserved || held.Status != 201 || string(held.Body) != `{"id":1}` || held.Header["ETag"] != `"1"` {
+		t.Errorf("Reserve of a completed key = %+v, %v, want the stored response", held, reserved)
+	}
+
+	expired := storage.IdempotentRequest{Scope: alice, Key: "create-2", ExpiresAt: time.Now().Add(-time.Second)}
+	if _, reserved, _ := s.ReserveIdempotencyKey(t.Context(), expired); !reserved {
+		t.Fatal("Reserve of a new key didn't get it")
+	}
+	if _, reserved, _ := s.ReserveIdempotencyKey(t.Context(), expired); !reserved {
+		t.Errorf("Reserve of an expired key didn't get it")
+	}
+}
@@ -1,60 +1,119 @@
 package postgre
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
 	"github.com/BahadirAhmedov/data-aggregation/internal/storage"
-	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/requests"
 	"github.com/lib/pq"
 )
 
 
+// subscriptionColumns are the columns scanSubscription reads, in order.
+const subscriptionColumns = "id, serviceName, price, currency, userId, startDate, endDate, deletedAt, version"
+
+const selectSubscriptions = "SELECT " + subscriptionColumns + " FROM subscriptions"
+
+
 type Storage struct{
 	db *sql.DB
+	// timeout bounds every query on top of the caller's context.
+	timeout time.Duration
 }
 
-func New(host string, port int, user string, password string, dbname string)(*Storage){
+// Pool tunes the connection pool of the Storage.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-    "password=%s dbname=%s sslmode=disable",
-    host, port, user, password, dbname)
-	
-	db, err := sql.Open("postgres", psqlInfo)
+// New connects to the database at dsn, a postgres:// URL or a libpq
+// key=value string.
+func New(dsn string, timeout time.Duration, pool Pool)(*Storage){
+
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
   		panic(err)
 	}  	
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
 	
-	err = db.Ping()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = db.PingContext(ctx)
   	if err != nil {
     	panic(err)
   	}
 
-  	fmt.Println("Successfully connected!")
-
-	return &Storage{db: db}
+	return &Storage{db: db, timeout: timeout}
 }
 
 
-func (s *Storage) Create(req requests.CreateSubscriptionRequest)(int64, error){
-	const op = "storage.postgre.Create"
+// Health pings the database and reads the schema version left by the
+// migrator. A missing or dirty version means the schema can't be trusted.
+func (s *Storage) Health(ctx context.Context) (storage.Health, error) {
+	const op = "storage.postgre.Health"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return storage.Health{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version uint
+	var dirty bool
+
+	err := s.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Health{}, fmt.Errorf("%s: %w", op, storage.ErrNoMigrations)
+	}
+
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == storage.UndefinedTable {
+		return storage.Health{}, fmt.Errorf("%s: %w", op, storage.ErrNoMigrations)
+	}
 
-	layout := "01-2006"
-	parsed, err := time.Parse(layout, req.StartDate)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, storage.ErrInvalidStartDateFormat)
+		return storage.Health{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var id int64
+	if dirty {
+		return storage.Health{MigrationVersion: version}, fmt.Errorf("%s: version %d: %w", op, version, storage.ErrDirtyMigration)
+	}
+
+	return storage.Health{MigrationVersion: version}, nil
+}
+
+
+// DB is the connection pool of the storage, for reporting its stats.
+func (s *Storage) DB() *sql.DB {
+	return s.db
+}
+
 
-	err = s.db.QueryRow("INSERT INTO subscriptions(serviceName, price, userId, startDate) VALUES($1, $2, $3, $4)  RETURNING id", req.ServiceName, req.Price, req.UserID, parsed).Scan(&id)
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+
+func (s *Storage) Create(ctx context.Context, subscription models.Subscription)(int64, error){
+	const op = "storage.postgre.Create"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	id, err := create(ctx, s.db, subscription)
 	if err != nil {
-		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == storage.UniqueViolation{
-			return 0, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionExists)			
-		}		
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -62,20 +121,17 @@ func (s *Storage) Create(req requests.CreateSubscriptionRequest)(int64, error){
 }
 
 
-func (s *Storage) Read(id int64) (models.Subscription, error){
+func (s *Storage) Read(ctx context.Context, id int64) (models.Subscription, error){
 	const op = "storage.postgre.Read"
 
-	row := s.db.QueryRow("SELECT id, serviceName, price, userId, TO_CHAR(startDate, 'MM-YYYY') AS startDate FROM subscriptions WHERE id = $1", id)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
 
+	row := s.db.QueryRowContext(ctx, selectSubscriptions+" WHERE id = $1 AND deletedAt IS NULL", id)
 
-	var subscription models.Subscription
-
-	err := row.Scan(&subscription.Id ,&subscription.ServiceName, &subscription.Price, &subscription.UserID, &subscription.StartDate)
-	
-
+	subscription, err := scanSubscription(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			fmt.Println(err)
 			return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
 		}
 		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
@@ -87,104 +143,887 @@ func (s *Storage) Read(id int64) (models.Subscription, error){
 
 
 
-func (s *Storage) List() ([]models.Subscription, error){
+// Owner returns the user_id of the subscription, whether it is deleted or not.
+func (s *Storage) Owner(ctx context.Context, Id int64) (string, error){
+	const op = "storage.postgre.Owner"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var userID string
+
+	err := s.db.QueryRowContext(ctx, "SELECT userId FROM subscriptions WHERE id = $1", Id).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+
+// sortColumns maps the sort fields of models.ListFilter to their columns.
+var sortColumns = map[string]string{
+	models.SortByID:        "id",
+	models.SortByPrice:     "price",
+	models.SortByStartDate: "startDate",
+}
+
+
+// List returns one page of subscriptions matching filter along with the total
+// number of matching subscriptions, which is only counted, and otherwise 0,
+// when filter.CountTotal is set.
+func (s *Storage) List(ctx context.Context, filter models.ListFilter) ([]models.Subscription, int64, error){
 	const op = "storage.postgre.List"
 
-	rows, err  := s.db.Query("SELECT id, serviceName, price, userId, TO_CHAR(startDate, 'MM-YYYY') AS startDate FROM subscriptions")
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var (
+		conditions []string
+		args []any
+	)
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != "" {
+		addCondition("userId = $%d", filter.UserID)
+	}
+	if filter.ServiceName != "" {
+		addCondition("serviceName = $%d", filter.ServiceName)
+	}
+	if filter.Currency != "" {
+		addCondition("currency = $%d", filter.Currency)
+	}
+	if filter.StartFrom != nil {
+		addCondition("startDate >= $%d", filter.StartFrom.Time())
+	}
+	if filter.StartTo != nil {
+		addCondition("startDate <= $%d", filter.StartTo.Time())
+	}
+	if filter.MinPrice != nil {
+		addCondition("price >= $%d", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		addCondition("price <= $%d", *filter.MaxPrice)
+	}
+
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deletedAt IS NULL")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+
+	column, ok := sortColumns[filter.SortBy]
+	if !ok {
+		column = sortColumns[models.SortByID]
+	}
+
+	var total int64
+
+	if filter.CountTotal {
+		err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM subscriptions"+where, args...).Scan(&total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// The page starts after the cursor with a row comparison on the sort
+	// column and id, which the (column, id) indexes serve without reading the
+	// rows of earlier pages the way OFFSET does. The count above leaves it
+	// out, it is of every page.
+	if after := filter.After; after != nil {
+		comparison := ">"
+		if filter.Desc {
+			comparison = "<"
+		}
+
+		switch filter.SortBy {
+		case models.SortByPrice, models.SortByStartDate:
+			var key any = after.Price
+			if filter.SortBy == models.SortByStartDate {
+				key = after.StartDate.Time()
+			}
+
+			args = append(args, key, after.ID)
+			conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+		default:
+			addCondition("id "+comparison+" $%d", after.ID)
+		}
+
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(
+		selectSubscriptions+"%s ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d",
+		where, column, direction, direction, len(args)+1, len(args)+2,
+	)
+
+	rows, err  := s.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
 	if err != nil {
-		return []models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	var subscriptions []models.Subscription
+	subscriptions := make([]models.Subscription, 0, filter.Limit)
 
 	for rows.Next() {
-		var subscription models.Subscription
-		err := rows.Scan(&subscription.Id ,&subscription.ServiceName, &subscription.Price, &subscription.UserID, &subscription.StartDate)
+		subscription, err := scanSubscription(rows)
 		if err != nil {
-			return []models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
 		}
 		subscriptions = append(subscriptions, subscription)
 	}
 
-	return subscriptions, nil
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscriptions, total, nil
+}
+
+
+// Export calls fn for every subscription in id order, reading rows as they
+// arrive instead of loading the whole table. Only ctx bounds it, since a full
+// export may take longer than the per-query timeout.
+func (s *Storage) Export(ctx context.Context, fn func(models.Subscription) error) error {
+	const op = "storage.postgre.Export"
+
+	err := s.each(ctx, selectSubscriptions+" WHERE deletedAt IS NULL ORDER BY id", nil, fn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+
+// costConditions selects the subscriptions that aren't deleted, match filter
+// and are billed in filter.Period, with the bounds of the period as $1 and $2.
+// prefix qualifies the columns.
+func costConditions(filter models.CostFilter, prefix string) (string, []any) {
+	args := []any{filter.Period.From.Time(), filter.Period.To.Time()}
+	conditions := []string{
+		prefix + "deletedAt IS NULL",
+		prefix + "startDate <= $2",
+		"(" + prefix + "endDate IS NULL OR " + prefix + "endDate >= $1)",
+	}
+
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("%suserId = $%d", prefix, len(args)))
+	}
+	if filter.ServiceName != "" {
+		args = append(args, filter.ServiceName)
+		conditions = append(conditions, fmt.Sprintf("%sserviceName = $%d", prefix, len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
 }
 
 
-func (s *Storage) Update(req requests.UpdateSubscriptionRequest, Id int64) (int64, error){
+// Sum adds up the cost of the subscriptions matching filter in SQL, one total
+// per currency. The per-subscription breakdown is only queried when detail is
+// set.
+func (s *Storage) Sum(ctx context.Context, filter models.CostFilter, detail bool) (models.SumResult, error){
+	const op = "storage.postgre.Sum"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	conditions, args := costConditions(filter, "")
+
+	// The overlap of a subscription with the period runs from the later of
+	// the two start months to the earlier of the two end months, and an
+	// open-ended subscription is treated as running until the period ends.
+	costs := fmt.Sprintf(
+	   `WITH costs AS (
+			SELECT id, serviceName, userId, price, currency, (
+				(EXTRACT(YEAR FROM LEAST(COALESCE(endDate, $2), $2)) - EXTRACT(YEAR FROM GREATEST(startDate, $1))) * 12
+				+ EXTRACT(MONTH FROM LEAST(COALESCE(endDate, $2), $2)) - EXTRACT(MONTH FROM GREATEST(startDate, $1))
+				+ 1
+			)::BIGINT AS months
+			FROM subscriptions
+			WHERE %s
+		)`, conditions)
+
+	result := models.SumResult{Totals: []models.Money{}}
+
+	totals, err := s.db.QueryContext(ctx, costs+" SELECT currency, SUM(price * months)::BIGINT, COUNT(*) FROM costs GROUP BY currency ORDER BY currency", args...)
+	if err != nil {
+		return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer totals.Close()
+
+	for totals.Next() {
+		var (
+			total models.Money
+			count int64
+		)
+
+		if err := totals.Scan(&total.Currency, &total.Amount, &count); err != nil {
+			return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		result.Totals = append(result.Totals, total)
+		result.Count += count
+	}
+
+	if err := totals.Err(); err != nil {
+		return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !detail {
+		return result, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, costs+" SELECT id, serviceName, userId, price, currency, months, price * months FROM costs ORDER BY id", args...)
+	if err != nil {
+		return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	result.Subscriptions = make([]models.SubscriptionCost, 0, result.Count)
+
+	for rows.Next() {
+		var cost models.SubscriptionCost
+
+		err := rows.Scan(&cost.Id, &cost.ServiceName, &cost.UserID, &cost.Price.Amount, &cost.Price.Currency, &cost.Months, &cost.Cost.Amount)
+		if err != nil {
+			return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+		cost.Cost.Currency = cost.Price.Currency
+
+		result.Subscriptions = append(result.Subscriptions, cost)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.SumResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+
+// reportColumns maps the GroupBy fields to the columns of the Report query.
+var reportColumns = map[string]string{
+	models.GroupByServiceName: "s.serviceName",
+	models.GroupByUserID:      "s.userId",
+	models.GroupByMonth:       "m.month",
+}
+
+
+// Report groups the cost of the subscriptions matching filter in SQL. Every
+// subscription is joined with each month of the period it is billed in, so a
+// group's total is the sum of the monthly prices in it.
+func (s *Storage) Report(ctx context.Context, filter models.CostFilter, groupBy []string) ([]models.CostGroup, error){
+	const op = "storage.postgre.Report"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	conditions, args := costConditions(filter, "s.")
+
+	var selects, groupColumns []string
+	for _, field := range groupBy {
+		column := reportColumns[field]
+
+		groupColumns = append(groupColumns, column)
+		if field == models.GroupByMonth {
+			column = "TO_CHAR(m.month, 'MM-YYYY')"
+		}
+		selects = append(selects, column)
+	}
+
+	groupColumns = append(groupColumns, "s.currency")
+	selects = append(selects, "s.currency")
+
+	query := fmt.Sprintf(
+	   `SELECT %s COUNT(DISTINCT s.id), SUM(s.price)::BIGINT
+		FROM subscriptions s
+		JOIN generate_series($1::DATE, $2::DATE, INTERVAL '1 month') AS m(month)
+			ON m.month >= s.startDate AND (s.endDate IS NULL OR m.month <= s.endDate)
+		WHERE %s`,
+		strings.Join(append(selects, ""), ", "), conditions,
+	)
+	query += fmt.Sprintf(" GROUP BY %[1]s ORDER BY %[1]s", strings.Join(groupColumns, ", "))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	groups := []models.CostGroup{}
+
+	for rows.Next() {
+		var group models.CostGroup
+
+		dest := make([]any, 0, len(groupBy)+3)
+		for _, field := range groupBy {
+			switch field {
+			case models.GroupByServiceName:
+				dest = append(dest, &group.ServiceName)
+			case models.GroupByUserID:
+				dest = append(dest, &group.UserID)
+			case models.GroupByMonth:
+				dest = append(dest, &group.Month)
+			}
+		}
+
+		if err := rows.Scan(append(dest, &group.Total.Currency, &group.Count, &group.Total.Amount)...); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+
+func (s *Storage) Update(ctx context.Context, subscription models.Subscription) (models.Subscription, error){
 	const op = "storage.postgre.Update"
 
-	layout := "01-2006"
-	parsed, err := time.Parse(layout, req.StartDate)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	subscription, err := update(ctx, s.db, subscription)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, storage.ErrInvalidStartDateFormat)
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var id int64
+	return subscription, nil
+}
+
+
+// Patch writes only the columns of the fields patch sets, with an UPDATE built
+// from them. The row is read under a lock first, so check sees the
+// subscription the UPDATE produces.
+func (s *Storage) Patch(ctx context.Context, Id int64, version int64, patch models.Patch, check func(models.Subscription) error) (models.Subscription, error){
+	const op = "storage.postgre.Patch"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	subscription, err := scanSubscription(tx.QueryRowContext(ctx, selectSubscriptions+" WHERE id = $1 AND deletedAt IS NULL FOR UPDATE", Id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+		}
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if version != 0 && subscription.Version != version {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrVersionMismatch)
+	}
+
+	patch.Apply(&subscription)
+	if err := check(subscription); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sets := []string{"version = version + 1"}
+	var args []any
+	set := func(column string, arg any) {
+		args = append(args, arg)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if patch.ServiceName != nil {
+		set("serviceName", subscription.ServiceName)
+	}
+	if patch.Price != nil {
+		set("price", subscription.Price.Amount)
+		set("currency", subscription.Price.Currency)
+	}
+	if patch.UserID != nil {
+		set("userId", subscription.UserID)
+	}
+	if patch.StartDate != nil {
+		set("startDate", subscription.StartDate.Time())
+	}
+	if patch.ClearEndDate || patch.EndDate != nil {
+		set("endDate", endDate(subscription))
+	}
+
+	args = append(args, Id)
+	query := fmt.Sprintf("UPDATE subscriptions SET %s WHERE id = $%d RETURNING %s", strings.Join(sets, ", "), len(args), subscriptionColumns)
+
+	subscription, err = scanSubscription(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == storage.UniqueViolation{
+			return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionExists)
+		}
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscription, nil
+}
+
+
+func (s *Storage) Delete(ctx context.Context, Id int64, version int64) error{
+	const op = "storage.postgre.Delete"
 
-	err = s.db.QueryRow("UPDATE subscriptions SET serviceName = $1, price = $2, userId = $3, startDate = $4 WHERE id = $5 RETURNING id", req.ServiceName, req.Price, req.UserID, parsed, Id).Scan(&id)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := remove(ctx, s.db, Id, version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+
+// Restore brings back a soft-deleted subscription. It fails with
+// storage.ErrSubscriptionExists if an identical subscription was created since.
+func (s *Storage) Restore(ctx context.Context, Id int64) (models.Subscription, error){
+	const op = "storage.postgre.Restore"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	row := s.db.QueryRowContext(ctx, "UPDATE subscriptions SET deletedAt = NULL, version = version + 1 WHERE id = $1 AND deletedAt IS NOT NULL RETURNING "+subscriptionColumns, Id)
+
+	subscription, err := scanSubscription(row)
 	if err != nil {
 		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == storage.UniqueViolation{
-			return 0, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionExists)			
+			return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionExists)
 		}
 
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)			
+			return models.Subscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
 		}
-	
-		return 0, fmt.Errorf("%s: %w", op, err)
+
+		return models.Subscription{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return id, nil
+	return subscription, nil
 }
 
 
+// History returns every recorded change of the subscription, oldest first,
+// including changes made before it was deleted. A subscription with no
+// recorded change has an empty history; only one that never existed is not
+// found.
+func (s *Storage) History(ctx context.Context, Id int64) ([]models.SubscriptionChange, error){
+	const op = "storage.postgre.History"
 
-func (s *Storage) Delete(Id int64) (int64, error){
-	const op = "storage.postgre.Delete"
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
 
-	var id int64 
+	rows, err := s.db.QueryContext(ctx, "SELECT id, subscriptionId, action, oldValue, newValue, changedAt FROM subscription_history WHERE subscriptionId = $1 ORDER BY changedAt, id", Id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var changes []models.SubscriptionChange
+
+	for rows.Next() {
+		var (
+			change models.SubscriptionChange
+			oldValue, newValue []byte
+		)
+
+		err := rows.Scan(&change.Id, &change.SubscriptionId, &change.Action, &oldValue, &newValue, &change.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 
-	err := s.db.QueryRow("DELETE FROM subscriptions WHERE id = $1 RETURNING id", Id).Scan(&id)
+		if change.OldValue, err = unmarshalSubscription(oldValue); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if change.NewValue, err = unmarshalSubscription(newValue); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(changes) == 0 {
+		var exists bool
+		if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1)", Id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if !exists {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)
+		}
+
+		return []models.SubscriptionChange{}, nil
+	}
+
+	return changes, nil
+}
+
+
+// Batch runs operations in one transaction. In atomic mode the first failing
+// operation rolls back the whole batch and Batch returns
+// storage.ErrBatchAborted; in partial mode every operation runs under its own
+// savepoint, so a failure only undoes that operation.
+func (s *Storage) Batch(ctx context.Context, operations []models.BatchOperation, atomic bool) ([]storage.BatchResult, error){
+	const op = "storage.postgre.Batch"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		fmt.Println(err)
-		if errors.Is(err, sql.ErrNoRows) {
-			return 0, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotFound)			
-		}		
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	results := make([]storage.BatchResult, len(operations))
+	for i, operation := range operations {
+		results[i].Op = operation.Op
+	}
+
+	for i, operation := range operations {
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_operation"); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		results[i].ID, err = applyBatchOperation(ctx, tx, operation)
+		if err != nil {
+			results[i].Err = err
+
+			if !isOperationError(err) {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			if atomic {
+				return results, fmt.Errorf("%s: %w", op, storage.ErrBatchAborted)
+			}
+
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_operation"); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			continue
+		}
+
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation"); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return results, nil
+}
+
+
+// ReserveIdempotencyKey claims request.Key for request.Scope until
+// request.ExpiresAt. When the key is held and hasn't expired, it returns the
+// request holding it and false instead. Expired keys are deleted first, so
+// they don't pile up and an expired key can be claimed again.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, request storage.IdempotentRequest) (storage.IdempotentRequest, bool, error) {
+	const op = "storage.postgre.ReserveIdempotencyKey"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expiresAt <= $1", time.Now())
+	if err != nil {
+		return storage.IdempotentRequest{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The no-op update makes a conflicting insert return the row holding the
+	// key, waiting for a concurrent insert to commit; xmax is 0 only for a row
+	// this statement inserted.
+	row := s.db.QueryRowContext(ctx, `INSERT INTO idempotency_keys (scope, idempotencyKey, requestHash, expiresAt) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (scope, idempotencyKey) DO UPDATE SET scope = EXCLUDED.scope
+		RETURNING requestHash, status, header, body, expiresAt, xmax = 0`, request.Scope, request.Key, request.Hash, request.ExpiresAt)
+
+	var (
+		held     = storage.IdempotentRequest{Scope: request.Scope, Key: request.Key}
+		status   sql.NullInt64
+		header   []byte
+		reserved bool
+	)
+
+	err = row.Scan(&held.Hash, &status, &header, &held.Body, &held.ExpiresAt, &reserved)
+	if err != nil {
+		return storage.IdempotentRequest{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	held.Status = int(status.Int64)
+
+	if header != nil {
+		if err := json.Unmarshal(header, &held.Header); err != nil {
+			return storage.IdempotentRequest{}, false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return held, reserved, nil
+}
+
+
+// CompleteIdempotencyKey stores the hash and response of the request holding
+// the key, for retries to replay.
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, request storage.IdempotentRequest) error {
+	const op = "storage.postgre.CompleteIdempotencyKey"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	header, err := json.Marshal(request.Header)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE idempotency_keys SET requestHash = $3, status = $4, header = $5, body = $6 WHERE scope = $1 AND idempotencyKey = $2", request.Scope, request.Key, request.Hash, request.Status, header, request.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+
+// ReleaseIdempotencyKey frees a key whose request got no response worth
+// replaying, so a retry runs again.
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	const op = "storage.postgre.ReleaseIdempotencyKey"
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE scope = $1 AND idempotencyKey = $2 AND status IS NULL", scope, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+
+// each runs query and calls fn for every subscription it selects, reading
+// rows as they arrive instead of loading them all.
+func (s *Storage) each(ctx context.Context, query string, args []any, fn func(models.Subscription) error) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(subscription); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+
+// scanSubscription reads a row selected with selectSubscriptions.
+func scanSubscription(row scanner) (models.Subscription, error) {
+	var (
+		subscription models.Subscription
+		startDate time.Time
+		endDate, deletedAt sql.NullTime
+	)
+
+	err := row.Scan(&subscription.Id ,&subscription.ServiceName, &subscription.Price.Amount, &subscription.Price.Currency, &subscription.UserID, &startDate, &endDate, &deletedAt, &subscription.Version)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	subscription.StartDate = models.MonthOf(startDate)
+
+	if endDate.Valid {
+		end := models.MonthOf(endDate.Time)
+		subscription.EndDate = &end
+	}
+
+	if deletedAt.Valid {
+		subscription.DeletedAt = &deletedAt.Time
+	}
+
+	return subscription, nil
+}
+
+
+// unmarshalSubscription decodes a history snapshot; NULL decodes to nil.
+func unmarshalSubscription(value []byte) (*models.Subscription, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	var subscription models.Subscription
+	if err := json.Unmarshal(value, &subscription); err != nil {
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+
+// querier is implemented by both *sql.DB and *sql.Tx, so the same statement
+// can run on its own or as part of a batch.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+
+func create(ctx context.Context, q querier, subscription models.Subscription) (int64, error) {
+	var id int64
+
+	err := q.QueryRowContext(ctx, "INSERT INTO subscriptions(serviceName, price, currency, userId, startDate, endDate) VALUES($1, $2, $3, $4, $5, $6)  RETURNING id", subscription.ServiceName, subscription.Price.Amount, subscription.Price.Currency, subscription.UserID, subscription.StartDate.Time(), endDate(subscription)).Scan(&id)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == storage.UniqueViolation{
+			return 0, storage.ErrSubscriptionExists
+		}
+		return 0, err
 	}
 
 	return id, nil
 }
 
 
-func (s *Storage) Sum(req requests.SumSubscriptionRequest) (int64, error){
-	const op = "storage.postgre.Sum"
+// update stores subscription if it is still at subscription.Version, or
+// whatever its version when that is 0, and returns it as stored.
+func update(ctx context.Context, q querier, subscription models.Subscription) (models.Subscription, error) {
+	row := q.QueryRowContext(ctx, "UPDATE subscriptions SET serviceName = $1, price = $2, currency = $3, userId = $4, startDate = $5, endDate = $6, version = version + 1 WHERE id = $7 AND deletedAt IS NULL AND ($8::BIGINT = 0 OR version = $8::BIGINT) RETURNING "+subscriptionColumns, subscription.ServiceName, subscription.Price.Amount, subscription.Price.Currency, subscription.UserID, subscription.StartDate.Time(), endDate(subscription), subscription.Id, subscription.Version)
 
-	layout := "01-2006"
-	
-	startDateParsed, err := time.Parse(layout, req.StartDate)
+	stored, err := scanSubscription(row)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, storage.ErrInvalidStartDateFormat)
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == storage.UniqueViolation{
+			return models.Subscription{}, storage.ErrSubscriptionExists
+		}
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Subscription{}, missing(ctx, q, subscription.Id)
+		}
+
+		return models.Subscription{}, err
 	}
 
-	endDateParsed, err := time.Parse(layout, req.EndDate)
+	return stored, nil
+}
+
+
+// remove soft-deletes the subscription if it is still at version, or whatever
+// its version when that is 0.
+func remove(ctx context.Context, q querier, Id int64, version int64) error {
+	var id int64
+
+	err := q.QueryRowContext(ctx, "UPDATE subscriptions SET deletedAt = NOW(), version = version + 1 WHERE id = $1 AND deletedAt IS NULL AND ($2::BIGINT = 0 OR version = $2::BIGINT) RETURNING id", Id, version).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, storage.ErrInvalidEndDateFormat)
+		if errors.Is(err, sql.ErrNoRows) {
+			return missing(ctx, q, Id)
+		}
+		return err
+	}
+
+	return nil
+}
+
+
+// missing tells why a conditional write of the subscription matched no row:
+// storage.ErrSubscriptionNotFound when it is gone, storage.ErrVersionMismatch
+// when it is at another version.
+func missing(ctx context.Context, q querier, Id int64) error {
+	var id int64
+
+	err := q.QueryRowContext(ctx, "SELECT id FROM subscriptions WHERE id = $1 AND deletedAt IS NULL", Id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrSubscriptionNotFound
 	}
 
-	var totalSum int64
-	
-	err = s.db.QueryRow(
-	   `SELECT SUM(price) 
-		FROM subscriptions 
-		WHERE userId = $1 
-			AND serviceName = $2 
-			AND startDate BETWEEN $3 AND $4`, req.UserID, req.ServiceName, startDateParsed, endDateParsed).Scan(&totalSum)
-	
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, storage.ErrUnableToCalculateSum)
+		return err
 	}
 
-	return totalSum, nil
-}
\ No newline at end of file
+	return storage.ErrVersionMismatch
+}
+
+
+// endDate is the endDate column of subscription, NULL when it is open-ended.
+func endDate(subscription models.Subscription) sql.NullTime {
+	if subscription.EndDate == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: subscription.EndDate.Time(), Valid: true}
+}
+
+
+func applyBatchOperation(ctx context.Context, tx *sql.Tx, operation models.BatchOperation) (int64, error) {
+	switch operation.Op {
+	case models.ActionCreate:
+		return create(ctx, tx, *operation.Subscription)
+	case models.ActionUpdate:
+		subscription := *operation.Subscription
+		subscription.Id = operation.ID
+		subscription.Version = operation.Version
+
+		if _, err := update(ctx, tx, subscription); err != nil {
+			return 0, err
+		}
+	default:
+		if err := remove(ctx, tx, operation.ID, operation.Version); err != nil {
+			return 0, err
+		}
+	}
+
+	return operation.ID, nil
+}
+
+
+// isOperationError reports whether err is caused by the operation itself
+// rather than by the database, so the rest of a batch can still run.
+func isOperationError(err error) bool {
+	return errors.Is(err, storage.ErrSubscriptionExists) ||
+		errors.Is(err, storage.ErrSubscriptionNotFound) ||
+		errors.Is(err, storage.ErrVersionMismatch)
+}
@@ -2,16 +2,52 @@ package storage
 
 import(
 	"errors"
+	"time"
 )
 
 const (
 	UniqueViolation = "23505"
+	UndefinedTable = "42P01"
 )
 
 var (
 	ErrSubscriptionExists = errors.New("subscription exists")
 	ErrSubscriptionNotFound = errors.New("subscription not found")
-	ErrInvalidStartDateFormat = errors.New("invalid start_date format")
-	ErrInvalidEndDateFormat = errors.New("invalid end_date format")
-	ErrUnableToCalculateSum = errors.New("unable to calculate the total cost of all subscriptions for a selected period")
-)
\ No newline at end of file
+	ErrVersionMismatch = errors.New("subscription version mismatch")
+	ErrBatchAborted = errors.New("batch aborted")
+	ErrNoMigrations = errors.New("no migrations applied")
+	ErrDirtyMigration = errors.New("last migration failed")
+)
+
+
+// Health is what a storage backend reports when it is ready to serve.
+// MigrationVersion is the applied schema version, 0 for backends without a
+// schema.
+type Health struct {
+	MigrationVersion uint
+}
+
+
+// BatchResult is the outcome of one operation of a batch, in request order.
+// Err is nil for operations that succeeded or were never run.
+type BatchResult struct {
+	Op  string
+	ID  int64
+	Err error
+}
+
+
+// IdempotentRequest is a request sent with an Idempotency-Key header, kept
+// under the key and the Scope of the caller so a retry can be answered with
+// the first response. Hash identifies the request the key was used for; it is
+// only known, like the response, once the first attempt has been answered.
+// Status is 0 until then.
+type IdempotentRequest struct {
+	Scope     string
+	Key       string
+	Hash      string
+	Status    int
+	Header    map[string]string
+	Body      []byte
+	ExpiresAt time.Time
+}
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/lib/api/httputil"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/requests"
+	"github.com/BahadirAhmedov/data-aggregation/internal/transport/http/responses"
+)
+
+// Formats of Import and Export.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var ErrNoCredentials = errors.New("no api key or token")
+
+
+// Error is a response of the API with a 4xx or 5xx status.
+type Error struct {
+	Status   int
+	Response httputil.Response
+}
+
+
+func (e *Error) Error() string {
+	msg := e.Response.Error
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s", e.Status, msg)
+
+	for _, field := range e.Response.Errors {
+		fmt.Fprintf(&b, "\n  %s: %s", field.Field, field.Message)
+	}
+
+	if e.Response.RequestID != "" {
+		fmt.Fprintf(&b, "\n  request id: %s", e.Response.RequestID)
+	}
+
+	return b.String()
+}
+
+
+// Client calls the subscriptions REST API. Requests and responses are the
+// types the server binds and answers with, so the two can't disagree.
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	apiKey  string
+	token   string
+}
+
+
+// New returns a client of the API at baseURL that authenticates with apiKey,
+// or with the bearer token when apiKey is empty. Calls are only limited by
+// their context, so imports and exports can stream as long as they need.
+func New(baseURL string, apiKey string, token string) (*Client, error) {
+	const op = "transport.http.client.New"
+
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%s: invalid url %q", op, baseURL)
+	}
+
+	if apiKey == "" && token == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoCredentials)
+	}
+
+	return &Client{
+		baseURL: u,
+		http:    &http.Client{},
+		apiKey:  apiKey,
+		token:   token,
+	}, nil
+}
+
+
+func (c *Client) Create(ctx context.Context, request requests.CreateSubscriptionRequest) (responses.CreateSubscriptionResponse, error) {
+	var resp responses.CreateSubscriptionResponse
+	err := c.doJSON(ctx, http.MethodPost, "/subscriptions", nil, nil, request, &resp)
+	return resp, err
+}
+
+
+func (c *Client) Get(ctx context.Context, id int64) (models.Subscription, error) {
+	var resp models.Subscription
+	err := c.doJSON(ctx, http.MethodGet, subscriptionPath(id), nil, nil, nil, &resp)
+	return resp, err
+}
+
+
+// List returns one page of subscriptions; the NextCursor of the response,
+// set as the Cursor of the request, gets the next one.
+func (c *Client) List(ctx context.Context, request requests.ListSubscriptionsRequest) (responses.ListSubscriptionsResponse, error) {
+	var resp responses.ListSubscriptionsResponse
+	err := c.doJSON(ctx, http.MethodGet, "/subscriptions", query(request), nil, nil, &resp)
+	return resp, err
+}
+
+
+// Update replaces the subscription if it is still at version, the Version
+// it was read with; zero overwrites any version.
+func (c *Client) Update(ctx context.Context, id int64, version int64, request requests.UpdateSubscriptionRequest) (responses.UpdateSubscriptionResponse, error) {
+	var resp responses.UpdateSubscriptionResponse
+	err := c.doJSON(ctx, http.MethodPut, subscriptionPath(id), nil, ifMatch(version), request, &resp)
+	return resp, err
+}
+
+
+// Delete deletes the subscription if it is still at version, like Update.
+func (c *Client) Delete(ctx context.Context, id int64, version int64) (responses.DeleteSubscriptionResponse, error) {
+	var resp responses.DeleteSubscriptionResponse
+	err := c.doJSON(ctx, http.MethodDelete, subscriptionPath(id), nil, ifMatch(version), nil, &resp)
+	return resp, err
+}
+
+
+// Sum sends Detail and ConvertTo as query parameters and the rest as the body,
+// as the server reads them.
+func (c *Client) Sum(ctx context.Context, request requests.SumSubscriptionRequest) (responses.SumSubscriptionResponse, error) {
+	var resp responses.SumSubscriptionResponse
+	err := c.doJSON(ctx, http.MethodPost, "/subscriptions/sum", query(request), nil, request, &resp)
+	return resp, err
+}
+
+
+// Import uploads subscriptions in format, FormatCSV or FormatNDJSON, as
+// written by Export.
+func (c *Client) Import(ctx context.Context, format string, body io.Reader) (responses.ImportSubscriptionsResponse, error) {
+	var resp responses.ImportSubscriptionsResponse
+	header := http.Header{"Content-Type": {contentType(format)}}
+	err := c.do(ctx, http.MethodPost, "/subscriptions/import", url.Values{"format": {format}}, header, body, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&resp)
+	})
+	return resp, err
+}
+
+
+// Export writes every subscription to w in format, FormatCSV or FormatNDJSON,
+// as the server streams it.
+func (c *Client) Export(ctx context.Context, format string, w io.Writer) error {
+	return c.do(ctx, http.MethodGet, "/subscriptions/export", url.Values{"format": {format}}, nil, nil, func(r io.Reader) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+
+func (c *Client) doJSON(ctx context.Context, method string, path string, params url.Values, header http.Header, request any, resp any) error {
+	var body io.Reader
+
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(data)
+
+		header = header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Type", "application/json")
+	}
+
+	return c.do(ctx, method, path, params, header, body, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(resp)
+	})
+}
+
+
+// do sends the request and hands a successful response body to read. Other
+// responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method string, path string, params url.Values, header http.Header, body io.Reader, read func(io.Reader) error) error {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &Error{Status: resp.StatusCode}
+		// Not every failure has a JSON body, e.g. one from a proxy.
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr.Response)
+
+		return apiErr
+	}
+
+	return read(resp.Body)
+}
+
+
+func subscriptionPath(id int64) string {
+	return "/subscriptions/" + strconv.FormatInt(id, 10)
+}
+
+
+// ifMatch is the If-Match header asking for version, or any version for zero.
+func ifMatch(version int64) http.Header {
+	if version == 0 {
+		return http.Header{"If-Match": {"*"}}
+	}
+	return http.Header{"If-Match": {`"` + strconv.FormatInt(version, 10) + `"`}}
+}
+
+
+func contentType(format string) string {
+	if format == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+
+// query encodes the fields of request with a form tag, the ones the server
+// binds from the query string. Zero values are left out, as the server treats
+// a missing parameter like its zero value.
+func query(request any) url.Values {
+	params := url.Values{}
+
+	v := reflect.ValueOf(request)
+	t := v.Type()
+
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+
+		if field.IsZero() && t.Field(i).Type.Kind() != reflect.Pointer {
+			continue
+		}
+
+		params.Set(name, fmt.Sprint(field.Interface()))
+	}
+
+	return params
+}
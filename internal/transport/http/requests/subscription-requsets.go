@@ -1,27 +1,323 @@
 package requests
 
+import (
+	"strings"
 
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+	"github.com/BahadirAhmedov/data-aggregation/internal/domain/service"
+)
+
+
+// CreateSubscriptionRequest is validated before it reaches storage: user_id is
+// a UUID, dates are MM-YYYY and end_date, when set, isn't before start_date.
 type CreateSubscriptionRequest struct {
-	ServiceName string  `json:"service_name" binding:"required"`
-	Price       int `json:"price" binding:"required"`
-	UserID      string  `json:"user_id" binding:"required"`
-	StartDate   string  `json:"start_date" binding:"required"`
+	ServiceName string  `json:"service_name" binding:"required,max=100"`
+	Price       models.Money `json:"price" binding:"required"`
+	UserID      string  `json:"user_id" binding:"required,uuid"`
+	StartDate   string  `json:"start_date" binding:"required,month_year"`
+	EndDate     string  `json:"end_date" binding:"omitempty,month_year,month_year_gte=StartDate"`
+}
+
+
+// Subscription is the subscription to create.
+func (r CreateSubscriptionRequest) Subscription() (models.Subscription, error) {
+	return UpdateSubscriptionRequest(r).Subscription()
 }
 
 
+// UpdateSubscriptionRequest replaces every field of a subscription. An omitted
+// end_date makes the subscription open-ended.
 type UpdateSubscriptionRequest struct {
-	ServiceName string  `json:"service_name"`
-	Price       int `json:"price"`
-	UserID      string  `json:"user_id"`
-	StartDate   string  `json:"start_date"`
+	ServiceName string  `json:"service_name" binding:"required,max=100"`
+	Price       models.Money `json:"price" binding:"required"`
+	UserID      string  `json:"user_id" binding:"required,uuid"`
+	StartDate   string  `json:"start_date" binding:"required,month_year"`
+	EndDate     string  `json:"end_date" binding:"omitempty,month_year,month_year_gte=StartDate"`
+}
+
+
+// Subscription is the subscription to replace the stored one with.
+func (r UpdateSubscriptionRequest) Subscription() (models.Subscription, error) {
+	startDate, err := models.ParseMonth(r.StartDate)
+	if err != nil {
+		return models.Subscription{}, service.ErrInvalidStartDateFormat
+	}
+
+	endDate, err := parseOptionalMonth(r.EndDate)
+	if err != nil {
+		return models.Subscription{}, service.ErrInvalidEndDateFormat
+	}
+
+	return models.Subscription{
+		ServiceName: r.ServiceName,
+		Price:       r.Price,
+		UserID:      r.UserID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+	}, nil
+}
+
+
+// PatchSubscriptionRequest changes only the fields present in the body. An
+// empty end_date makes the subscription open-ended.
+type PatchSubscriptionRequest struct {
+	ServiceName *string `json:"service_name" binding:"omitnil,min=1,max=100"`
+	Price       *models.Money `json:"price"`
+	UserID      *string `json:"user_id" binding:"omitnil,uuid"`
+	StartDate   *string `json:"start_date" binding:"omitnil,month_year"`
+	EndDate     *string `json:"end_date" binding:"omitnil,month_year_or_empty,month_year_gte=StartDate"`
+}
+
+
+func (r PatchSubscriptionRequest) Patch() (models.Patch, error) {
+	patch := models.Patch{
+		ServiceName: r.ServiceName,
+		Price:       r.Price,
+		UserID:      r.UserID,
+	}
+
+	if r.StartDate != nil {
+		startDate, err := models.ParseMonth(*r.StartDate)
+		if err != nil {
+			return models.Patch{}, service.ErrInvalidStartDateFormat
+		}
+		patch.StartDate = &startDate
+	}
+
+	if r.EndDate != nil {
+		endDate, err := parseOptionalMonth(*r.EndDate)
+		if err != nil {
+			return models.Patch{}, service.ErrInvalidEndDateFormat
+		}
+		patch.EndDate = endDate
+		patch.ClearEndDate = endDate == nil
+	}
+
+	return patch, nil
 }
 
 
 
 
+// SumSubscriptionRequest asks for the cost of subscriptions between StartDate
+// and EndDate inclusive. ServiceName and UserID narrow the sum down when set.
+// Detail comes from the ?detail query parameter and adds the cost of every
+// contributing subscription to the result. ConvertTo comes from ?convert_to
+// and adds the totals converted to that currency.
 type SumSubscriptionRequest struct {
-	ServiceName string  `json:"service_name" binding:"required"`
-	UserID      string  `json:"user_id" binding:"required"`
-	StartDate   string  `json:"start_date" binding:"required"`
-	EndDate     string  `json:"end_date" binding:"required"`
-}
\ No newline at end of file
+	ServiceName string  `json:"service_name"`
+	UserID      string  `json:"user_id" binding:"omitempty,uuid"`
+	StartDate   string  `json:"start_date" binding:"required,month_year"`
+	EndDate     string  `json:"end_date" binding:"required,month_year,month_year_gte=StartDate"`
+	Detail      bool    `json:"-" form:"detail"`
+	ConvertTo   string  `json:"-" form:"convert_to" binding:"omitempty,iso4217"`
+}
+
+
+func (r SumSubscriptionRequest) Filter() (models.CostFilter, error) {
+	period, err := parsePeriod(r.StartDate, r.EndDate)
+	if err != nil {
+		return models.CostFilter{}, err
+	}
+
+	return models.CostFilter{
+		Period:      period,
+		UserID:      r.UserID,
+		ServiceName: r.ServiceName,
+	}, nil
+}
+
+// ListSubscriptionsRequest holds the query parameters of GET /subscriptions.
+// Sort takes id, price or start_date, prefixed with "-" for descending order.
+// Prices are compared by amount in minor units, so price filters and sorting
+// are only meaningful together with Currency.
+// Cursor is the next_cursor of a previous page, requested with the same Sort,
+// and takes precedence over Offset. The total number of matching
+// subscriptions is only counted with IncludeTotal.
+type ListSubscriptionsRequest struct {
+	UserID         string `form:"user_id" binding:"omitempty,uuid"`
+	ServiceName    string `form:"service_name"`
+	Currency       string `form:"currency" binding:"omitempty,iso4217"`
+	StartDateFrom  string `form:"start_date_from" binding:"omitempty,month_year"`
+	StartDateTo    string `form:"start_date_to" binding:"omitempty,month_year"`
+	MinPrice       *int64 `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice       *int64 `form:"max_price" binding:"omitempty,min=0"`
+	Sort           string `form:"sort" binding:"omitempty,oneof=id -id price -price start_date -start_date"`
+	Limit          int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Offset         int    `form:"offset" binding:"omitempty,min=0"`
+	Cursor         string `form:"cursor"`
+	IncludeTotal   bool   `form:"include_total"`
+	// IncludeDeleted also lists soft-deleted subscriptions.
+	IncludeDeleted bool   `form:"include_deleted"`
+}
+
+
+// Filter is the filter of the request. It leaves Cursor to the caller, which
+// decodes it into After.
+func (r ListSubscriptionsRequest) Filter() (models.ListFilter, error) {
+	filter := models.ListFilter{
+		UserID:         r.UserID,
+		ServiceName:    r.ServiceName,
+		Currency:       r.Currency,
+		MinPrice:       r.MinPrice,
+		MaxPrice:       r.MaxPrice,
+		IncludeDeleted: r.IncludeDeleted,
+		SortBy:         strings.TrimPrefix(r.Sort, "-"),
+		Desc:           strings.HasPrefix(r.Sort, "-"),
+		Limit:          r.Limit,
+		Offset:         r.Offset,
+		CountTotal:     r.IncludeTotal,
+	}
+
+	var err error
+
+	if filter.StartFrom, err = parseOptionalMonth(r.StartDateFrom); err != nil {
+		return models.ListFilter{}, service.ErrInvalidStartDateFormat
+	}
+
+	if filter.StartTo, err = parseOptionalMonth(r.StartDateTo); err != nil {
+		return models.ListFilter{}, service.ErrInvalidStartDateFormat
+	}
+
+	return filter, nil
+}
+
+
+const (
+	BatchOpCreate = models.ActionCreate
+	BatchOpUpdate = models.ActionUpdate
+	BatchOpDelete = models.ActionDelete
+
+	// BatchModeAtomic applies every operation of a batch or none of them.
+	BatchModeAtomic = "atomic"
+	// BatchModePartial keeps the operations that succeed and reports the rest.
+	BatchModePartial = "partial"
+)
+
+
+// BatchSubscriptionRequest is the body of POST /subscriptions/batch. Items of
+// Subscriptions are created after the explicit Operations, in order.
+type BatchSubscriptionRequest struct {
+	Mode          string                      `json:"mode" binding:"omitempty,oneof=atomic partial"`
+	Operations    []BatchOperation            `json:"operations" binding:"dive"`
+	Subscriptions []CreateSubscriptionRequest `json:"subscriptions" binding:"dive"`
+}
+
+
+func (r BatchSubscriptionRequest) Atomic() bool {
+	return r.Mode != BatchModePartial
+}
+
+
+// BatchOperations converts Operations followed by Subscriptions.
+func (r BatchSubscriptionRequest) BatchOperations() ([]models.BatchOperation, error) {
+	operations := make([]models.BatchOperation, 0, len(r.Operations)+len(r.Subscriptions))
+
+	for _, operation := range r.Operations {
+		converted, err := operation.BatchOperation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, converted)
+	}
+
+	for _, subscription := range r.Subscriptions {
+		converted, err := subscription.Subscription()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, models.BatchOperation{Op: BatchOpCreate, Subscription: &converted})
+	}
+
+	return operations, nil
+}
+
+
+// BatchOperation is a single create, update or delete of a batch. ID is
+// required for update and delete, Subscription for create and update. An
+// update or delete fails unless the subscription is still at Version, like
+// If-Match; Force runs it at any version instead, like If-Match: *.
+type BatchOperation struct {
+	Op           string                     `json:"op" binding:"required,oneof=create update delete"`
+	ID           int64                      `json:"id"`
+	Version      int64                      `json:"version" binding:"min=0"`
+	Force        bool                       `json:"force"`
+	Subscription *UpdateSubscriptionRequest `json:"subscription"`
+}
+
+
+// BatchOperation leaves checking that the fields Op needs are there to the
+// service.
+func (o BatchOperation) BatchOperation() (models.BatchOperation, error) {
+	operation := models.BatchOperation{Op: o.Op, ID: o.ID, Version: o.Version, Force: o.Force}
+
+	if o.Subscription != nil {
+		subscription, err := o.Subscription.Subscription()
+		if err != nil {
+			return models.BatchOperation{}, err
+		}
+		operation.Subscription = &subscription
+	}
+
+	return operation, nil
+}
+
+
+// ReportSubscriptionsRequest asks for the cost of subscriptions between
+// StartDate and EndDate inclusive, split into groups by any combination of
+// service_name, user_id and month. Groups are always split by currency as well,
+// so without GroupBy there is one group per currency. ConvertTo comes from the
+// ?convert_to query parameter and adds the total converted to that currency.
+type ReportSubscriptionsRequest struct {
+	StartDate   string   `json:"start_date" binding:"required,month_year"`
+	EndDate     string   `json:"end_date" binding:"required,month_year,month_year_gte=StartDate"`
+	UserID      string   `json:"user_id" binding:"omitempty,uuid"`
+	ServiceName string   `json:"service_name"`
+	GroupBy     []string `json:"group_by" binding:"omitempty,unique,dive,oneof=service_name user_id month"`
+	ConvertTo   string   `json:"-" form:"convert_to" binding:"omitempty,iso4217"`
+}
+
+
+func (r ReportSubscriptionsRequest) Filter() (models.CostFilter, error) {
+	period, err := parsePeriod(r.StartDate, r.EndDate)
+	if err != nil {
+		return models.CostFilter{}, err
+	}
+
+	return models.CostFilter{
+		Period:      period,
+		UserID:      r.UserID,
+		ServiceName: r.ServiceName,
+	}, nil
+}
+
+
+func parsePeriod(startDate string, endDate string) (models.Period, error) {
+	from, err := models.ParseMonth(startDate)
+	if err != nil {
+		return models.Period{}, service.ErrInvalidStartDateFormat
+	}
+
+	to, err := models.ParseMonth(endDate)
+	if err != nil {
+		return models.Period{}, service.ErrInvalidEndDateFormat
+	}
+
+	return models.Period{From: from, To: to}, nil
+}
+
+
+// parseOptionalMonth parses an MM-YYYY month that may be left empty, which
+// gives nil.
+func parseOptionalMonth(s string) (*models.Month, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	month, err := models.ParseMonth(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &month, nil
+}
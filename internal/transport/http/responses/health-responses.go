@@ -0,0 +1,16 @@
+package responses
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusShutdown    = "shutting down"
+)
+
+
+// HealthResponse is the body of /healthz and /readyz. MigrationVersion and
+// Error are only set by /readyz.
+type HealthResponse struct {
+	Status           string `json:"status"`
+	MigrationVersion *uint  `json:"migration_version,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
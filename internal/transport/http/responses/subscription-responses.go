@@ -1,20 +1,26 @@
 package responses
 
+import "github.com/BahadirAhmedov/data-aggregation/internal/domain/models"
+
 type CreateSubscriptionResponse struct {
 	Id int64 `json:"id"`
 	ServiceName string  `json:"service_name"`
-	Price       int `json:"price"`
+	Price       models.Money `json:"price"`
 	UserID      string  `json:"user_id"`
-	StartDate   string  `json:"start_date"`
+	StartDate   models.Month  `json:"start_date" swaggertype:"string" example:"07-2025"`
+	EndDate     *models.Month `json:"end_date,omitempty" swaggertype:"string" example:"12-2025"`
+	Version     int64         `json:"version"`
 }
 
 
 type UpdateSubscriptionResponse struct {
 	Id int64 `json:"id"`
 	ServiceName string  `json:"service_name"`
-	Price       int `json:"price"`
+	Price       models.Money `json:"price"`
 	UserID      string  `json:"user_id"`
-	StartDate   string  `json:"start_date"`
+	StartDate   models.Month  `json:"start_date" swaggertype:"string" example:"07-2025"`
+	EndDate     *models.Month `json:"end_date,omitempty" swaggertype:"string" example:"12-2025"`
+	Version     int64         `json:"version"`
 }
 
 
@@ -25,5 +31,62 @@ type DeleteSubscriptionResponse struct {
 
 
 type SumSubscriptionResponse struct {
-	TotalSum int64 `json:"total_sum"`
+	// Totals holds one total per currency; amounts in different currencies
+	// are never added up.
+	Totals        []models.Money            `json:"totals"`
+	// Converted is the sum of Totals in the ?convert_to currency.
+	Converted     *models.Money             `json:"converted,omitempty"`
+	Count         int64                     `json:"count"`
+	Subscriptions []models.SubscriptionCost `json:"subscriptions,omitempty"`
+}
+
+
+// ListSubscriptionsResponse is a page of subscriptions. Total is only there
+// when the request asked for it with include_total.
+type ListSubscriptionsResponse struct {
+	Subscriptions []models.Subscription `json:"subscriptions"`
+	Total         *int64                `json:"total,omitempty"`
+	NextCursor    string                `json:"next_cursor,omitempty"`
+}
+
+
+type BatchSubscriptionResponse struct {
+	// Applied reports whether the changes were committed. It is false only when
+	// an atomic batch was rolled back.
+	Applied bool              `json:"applied"`
+	Results []BatchItemResult `json:"results"`
+}
+
+
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Id     int64  `json:"id,omitempty"`
+	Status string `json:"status"`
+	// Code is the httputil.Response code of the error of a failed operation.
+	Code   string `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+
+type ImportSubscriptionsResponse struct {
+	Imported int               `json:"imported"`
+	Failed   int               `json:"failed"`
+	Errors   []ImportLineError `json:"errors"`
+}
+
+
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+
+type ReportSubscriptionsResponse struct {
+	GroupBy   []string           `json:"group_by"`
+	Groups    []models.CostGroup `json:"groups"`
+	// Totals holds one total per currency, ordered by currency code.
+	Totals    []models.Money     `json:"totals"`
+	// Converted is the sum of Totals in the ?convert_to currency.
+	Converted *models.Money      `json:"converted,omitempty"`
 }
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_end_date_check;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS endDate;
@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS endDate DATE;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_end_date_check CHECK (endDate IS NULL OR endDate >= startDate);
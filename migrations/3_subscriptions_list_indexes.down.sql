@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS subscriptions_price_idx;
+DROP INDEX IF EXISTS subscriptions_start_date_idx;
+DROP INDEX IF EXISTS subscriptions_service_name_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS subscriptions_service_name_idx ON subscriptions (serviceName);
+CREATE INDEX IF NOT EXISTS subscriptions_start_date_idx ON subscriptions (startDate, id);
+CREATE INDEX IF NOT EXISTS subscriptions_price_idx ON subscriptions (price, id);
@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS subscriptions_history ON subscriptions;
+DROP FUNCTION IF EXISTS record_subscription_history();
+DROP FUNCTION IF EXISTS subscription_json(subscriptions);
+
+DROP TABLE IF EXISTS subscription_history;
+
+-- Soft-deleted rows would violate the restored constraint.
+DELETE FROM subscriptions WHERE deletedAt IS NOT NULL;
+
+DROP INDEX IF EXISTS subscriptions_active_key;
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_userid_servicename_startdate_key UNIQUE (userId, serviceName, startDate);
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS deletedAt;
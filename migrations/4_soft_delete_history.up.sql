@@ -0,0 +1,67 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS deletedAt TIMESTAMPTZ;
+
+-- Deleted subscriptions must not block creating the same one again.
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_userid_servicename_startdate_key;
+CREATE UNIQUE INDEX IF NOT EXISTS subscriptions_active_key
+    ON subscriptions (userId, serviceName, startDate)
+    WHERE deletedAt IS NULL;
+
+-- History rows outlive the subscription, so there is no foreign key.
+CREATE TABLE IF NOT EXISTS subscription_history
+(
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscriptionId BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    oldValue JSONB,
+    newValue JSONB,
+    changedAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS subscription_history_subscription_idx
+    ON subscription_history (subscriptionId, changedAt);
+
+-- subscription_json renders a row with the field names of the API model.
+CREATE OR REPLACE FUNCTION subscription_json(s subscriptions) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', s.id,
+        'service_name', s.serviceName,
+        'price', s.price,
+        'user_id', s.userId,
+        'start_date', TO_CHAR(s.startDate, 'MM-YYYY'),
+        'end_date', TO_CHAR(s.endDate, 'MM-YYYY')
+    )
+$$ LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE FUNCTION record_subscription_history() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO subscription_history (subscriptionId, action, newValue)
+        VALUES (NEW.id, 'create', subscription_json(NEW));
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO subscription_history (subscriptionId, action, oldValue)
+        VALUES (OLD.id, 'delete', subscription_json(OLD));
+    ELSIF OLD.deletedAt IS NULL AND NEW.deletedAt IS NOT NULL THEN
+        INSERT INTO subscription_history (subscriptionId, action, oldValue)
+        VALUES (OLD.id, 'delete', subscription_json(OLD));
+    ELSIF OLD.deletedAt IS NOT NULL AND NEW.deletedAt IS NULL THEN
+        INSERT INTO subscription_history (subscriptionId, action, newValue)
+        VALUES (NEW.id, 'restore', subscription_json(NEW));
+    ELSE
+        INSERT INTO subscription_history (subscriptionId, action, oldValue, newValue)
+        VALUES (NEW.id, 'update', subscription_json(OLD), subscription_json(NEW));
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Subscriptions that exist already get the create entry the trigger would
+-- have recorded for them, with their current values.
+INSERT INTO subscription_history (subscriptionId, action, newValue)
+SELECT s.id, 'create', subscription_json(s)
+FROM subscriptions s
+ORDER BY s.id;
+
+CREATE TRIGGER subscriptions_history
+    AFTER INSERT OR UPDATE OR DELETE ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION record_subscription_history();
@@ -0,0 +1,25 @@
+-- Going back drops the currency, so amounts billed in anything other than RUB
+-- are kept as if they were rubles.
+CREATE OR REPLACE FUNCTION subscription_json(s subscriptions) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', s.id,
+        'service_name', s.serviceName,
+        'price', s.price,
+        'user_id', s.userId,
+        'start_date', TO_CHAR(s.startDate, 'MM-YYYY'),
+        'end_date', TO_CHAR(s.endDate, 'MM-YYYY')
+    )
+$$ LANGUAGE SQL STABLE;
+
+UPDATE subscription_history
+SET oldValue = jsonb_set(oldValue, '{price}', to_jsonb((oldValue->'price'->>'amount')::BIGINT / 100))
+WHERE jsonb_typeof(oldValue->'price') = 'object';
+
+UPDATE subscription_history
+SET newValue = jsonb_set(newValue, '{price}', to_jsonb((newValue->'price'->>'amount')::BIGINT / 100))
+WHERE jsonb_typeof(newValue->'price') = 'object';
+
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_currency_check;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE subscriptions ALTER COLUMN price TYPE INT USING (price / 100)::INT;
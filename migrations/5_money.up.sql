@@ -0,0 +1,27 @@
+-- Prices so far were whole rubles; store them in kopecks from now on.
+ALTER TABLE subscriptions ALTER COLUMN price TYPE BIGINT USING price::BIGINT * 100;
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE subscriptions ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+
+-- History snapshots follow the API model, where price is now an object.
+UPDATE subscription_history
+SET oldValue = jsonb_set(oldValue, '{price}', jsonb_build_object('amount', (oldValue->>'price')::BIGINT * 100, 'currency', 'RUB'))
+WHERE jsonb_typeof(oldValue->'price') = 'number';
+
+UPDATE subscription_history
+SET newValue = jsonb_set(newValue, '{price}', jsonb_build_object('amount', (newValue->>'price')::BIGINT * 100, 'currency', 'RUB'))
+WHERE jsonb_typeof(newValue->'price') = 'number';
+
+CREATE OR REPLACE FUNCTION subscription_json(s subscriptions) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', s.id,
+        'service_name', s.serviceName,
+        'price', jsonb_build_object('amount', s.price, 'currency', s.currency),
+        'user_id', s.userId,
+        'start_date', TO_CHAR(s.startDate, 'MM-YYYY'),
+        'end_date', TO_CHAR(s.endDate, 'MM-YYYY')
+    )
+$$ LANGUAGE SQL STABLE;
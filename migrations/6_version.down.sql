@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION subscription_json(s subscriptions) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', s.id,
+        'service_name', s.serviceName,
+        'price', jsonb_build_object('amount', s.price, 'currency', s.currency),
+        'user_id', s.userId,
+        'start_date', TO_CHAR(s.startDate, 'MM-YYYY'),
+        'end_date', TO_CHAR(s.endDate, 'MM-YYYY')
+    )
+$$ LANGUAGE SQL STABLE;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS version;
@@ -0,0 +1,15 @@
+-- version counts the writes to a subscription, so a client can make its write
+-- conditional on the version it read.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION subscription_json(s subscriptions) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', s.id,
+        'service_name', s.serviceName,
+        'price', jsonb_build_object('amount', s.price, 'currency', s.currency),
+        'user_id', s.userId,
+        'start_date', TO_CHAR(s.startDate, 'MM-YYYY'),
+        'end_date', TO_CHAR(s.endDate, 'MM-YYYY'),
+        'version', s.version
+    )
+$$ LANGUAGE SQL STABLE;
@@ -0,0 +1,18 @@
+-- idempotency_keys remembers requests sent with an Idempotency-Key header, so
+-- a retry is answered with the response of the first attempt. requestHash is
+-- empty and status, header and body stay NULL while the first attempt is
+-- running.
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    scope TEXT NOT NULL,
+    idempotencyKey TEXT NOT NULL,
+    requestHash TEXT NOT NULL,
+    status INT,
+    header JSONB,
+    body BYTEA,
+    expiresAt TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, idempotencyKey)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx
+    ON idempotency_keys (expiresAt);
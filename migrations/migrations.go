@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the subscriptions schema so
+// the migrator binary carries them along.
+package migrations
+
+import "embed"
+
+// FS holds the N_name.up.sql and N_name.down.sql files of golang-migrate.
+//
+//go:embed *.sql
+var FS embed.FS